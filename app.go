@@ -2,15 +2,24 @@ package cwl
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
+	// fps is the most frames rendered per second. Changes arriving faster,
+	// such as a burst of log events, are drawn together in the next frame.
 	fps = 30
+	// statusRows are the rows at the bottom kept for the status bar.
+	statusRows = 1
 )
 
 type RenderParameter struct {
@@ -19,23 +28,92 @@ type RenderParameter struct {
 }
 
 type App struct {
-	mu       sync.Mutex
-	tty      *TTY
-	screen   Screen
-	logs     []*LogGroup
-	selected []*LogGroup
-	cfg      *Config
+	mu               sync.Mutex
+	tty              Terminal
+	renderer         *Renderer
+	redraw           chan struct{}
+	theme            *Theme
+	keymap           *Keymap
+	correlator       *Correlator
+	multiline        *Multiline
+	keys             keySequence
+	help             bool
+	command          *commandLine
+	err              error
+	location         *time.Location
+	screen           Screen
+	logs             []*LogGroup
+	selected         []*LogGroup
+	cfg              *Config
+	cache            *LogGroupCache
+	profiles         []string
+	profileSelection *ProfileSelection
+	ssoSessions      []*SSOSession
+	endpointURL      string
+	recorder         io.Writer
+
+	refreshing atomic.Bool
 }
 
-func NewApp() *App {
-	tty, err := NewTTY()
-	if err != nil {
-		panic(err)
+type Option func(*App)
+
+// WithEndpointURL points every CloudWatch Logs client at endpointURL, such as
+// a LocalStack instance, regardless of the config file.
+func WithEndpointURL(endpointURL string) Option {
+	return func(a *App) {
+		a.endpointURL = endpointURL
 	}
+}
 
-	return &App{
-		tty:    tty,
-		screen: NewLoadingScreen(),
+// WithTerminal runs the app in t instead of the controlling terminal.
+func WithTerminal(t Terminal) Option {
+	return func(a *App) {
+		a.tty = t
+	}
+}
+
+// WithRecorder copies all terminal output to w.
+func WithRecorder(w io.Writer) Option {
+	return func(a *App) {
+		a.recorder = w
+	}
+}
+
+func NewApp(opts ...Option) *App {
+	a := &App{
+		redraw:     make(chan struct{}, 1),
+		theme:      DefaultTheme,
+		keymap:     DefaultKeymap,
+		correlator: DefaultCorrelator,
+		multiline:  DefaultMultiline,
+	}
+	loading := NewLoadingScreen(nil, nil)
+	loading.redraw = a.Redraw
+	a.screen = loading
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.tty == nil {
+		tty, err := NewTTY()
+		if err != nil {
+			panic(err)
+		}
+		a.tty = tty
+	}
+	if a.recorder != nil {
+		a.tty = NewRecorder(a.tty, a.recorder)
+	}
+	a.renderer = NewRenderer(a.tty)
+	a.renderer.Reserve(statusRows)
+	return a
+}
+
+// Redraw requests a frame. It can be called from any goroutine and does not
+// block.
+func (a *App) Redraw() {
+	select {
+	case a.redraw <- struct{}{}:
+	default:
 	}
 }
 
@@ -45,33 +123,401 @@ func (a *App) ShowLoading(ctx context.Context) error {
 		return err
 	}
 	a.cfg = cfg
+	a.cfg.endpointURLOverride = a.endpointURL
 
-	cfgs, err := LoadAWSConfigs(ctx, a.cfg.ExcludeProfiles)
+	theme, err := a.cfg.LoadTheme()
+	if err != nil {
+		return err
+	}
+	keymap, err := a.cfg.LoadKeymap()
+	if err != nil {
+		return err
+	}
+	correlator, err := a.cfg.LoadCorrelator()
+	if err != nil {
+		return err
+	}
+	multiline, err := a.cfg.LoadMultiline()
 	if err != nil {
 		return err
 	}
+	a.mu.Lock()
+	a.theme = theme
+	a.keymap = keymap
+	a.correlator = correlator
+	a.multiline = multiline
+	a.mu.Unlock()
+
+	a.cache, err = LoadDefaultLogGroupCache()
+	if err != nil {
+		a.cache = &LogGroupCache{Entries: make(map[string]*LogGroupCacheEntry)}
+	}
 
-	a.logs, err = GetLogGroups(ctx, cfgs)
+	a.profiles, err = LoadProfiles(a.cfg)
 	if err != nil {
 		return err
 	}
 
-	return a.ShowChooseLogsScreen(ctx)
+	a.profileSelection, err = LoadDefaultProfileSelection()
+	if err != nil {
+		a.profileSelection = &ProfileSelection{}
+	}
+
+	if !a.profileSelection.Saved() && len(a.profiles) > 1 {
+		picked := make(chan struct{})
+		a.mu.Lock()
+		picker := NewProfilePickerScreen(a.profiles, a.profileSelection.Disabled, func(disabled []string) {
+			a.profileSelection.Disabled = disabled
+			if err := a.profileSelection.Save(); err != nil {
+				a.err = fmt.Errorf("profile selection: %w", err)
+			}
+			close(picked)
+		})
+		picker.theme = a.theme
+		picker.keymap = a.keymap
+		a.screen = picker
+		a.mu.Unlock()
+		a.Redraw()
+		select {
+		case <-picked:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	a.mu.Lock()
+	enabled := a.enabledProfiles()
+	a.mu.Unlock()
+	if logs := GetCachedLogGroups(ctx, a.cfg, a.cache, enabled); len(logs) > 0 {
+		a.mu.Lock()
+		a.logs = logs
+		err := a.ShowChooseLogsScreen(ctx)
+		a.mu.Unlock()
+		a.Redraw()
+		if err != nil {
+			return err
+		}
+		if a.cache.Expired(a.cfg.ProfileRegionSet(ctx, enabled), a.cfg.LogGroupCacheTTL()) {
+			go a.RefreshLogGroups(ctx)
+		}
+		return nil
+	}
+
+	if !a.refreshing.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer a.refreshing.Store(false)
+
+	progress := a.newLoadProgress()
+	a.mu.Lock()
+	loading := NewLoadingScreen(progress, func() {
+		a.logs = progress.LogGroups()
+		a.ShowChooseLogsScreen(ctx)
+	})
+	loading.theme = a.theme
+	loading.keymap = a.keymap
+	loading.redraw = a.Redraw
+	loading.login = func(sessions []*SSOSession) {
+		a.ShowSSOLoginScreen(ctx, sessions)
+	}
+	a.screen = loading
+	profiles := a.enabledProfiles()
+	a.mu.Unlock()
+	a.Redraw()
+
+	if err := a.refreshLogGroups(ctx, profiles, progress); err != nil {
+		// Stay on the loading screen so the user can sign in to the
+		// expired SSO sessions.
+		if len(progress.SSOSessions()) > 0 {
+			return nil
+		}
+		return err
+	}
+
+	defer a.Redraw()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.screen.(*LoadingScreen); ok {
+		return a.ShowChooseLogsScreen(ctx)
+	}
+	return nil
+}
+
+// RefreshLogGroups discovers log groups of every profile in the background of
+// the current screen, stores them in the cache and merges them into the log
+// groups shown to the user.
+func (a *App) RefreshLogGroups(ctx context.Context) error {
+	if !a.refreshing.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer a.refreshing.Store(false)
+
+	a.mu.Lock()
+	profiles := a.enabledProfiles()
+	a.mu.Unlock()
+
+	err := a.refreshLogGroups(ctx, profiles, a.newLoadProgress())
+	if err != nil {
+		a.setError(err)
+		a.setChooseStatus(fmt.Sprintf("Refresh failed: %s", err))
+	}
+	return err
+}
+
+// discoverProfiles discovers log groups of profiles only, such as the ones
+// of an SSO session that was just signed in.
+func (a *App) discoverProfiles(ctx context.Context, profiles []string) error {
+	err := a.refreshLogGroups(ctx, profiles, a.newLoadProgress())
+	if err != nil {
+		a.setError(err)
+		a.setChooseStatus(fmt.Sprintf("Discovery failed: %s", err))
+	}
+	return err
+}
+
+func (a *App) refreshLogGroups(ctx context.Context, profiles []string, progress *LoadProgress) error {
+	cfgs, logs, err := DiscoverLogGroups(ctx, a.cfg, profiles, progress)
+	a.updateSSOSessions(profiles, progress.SSOSessions())
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	enabled := a.enabledProfiles()
+	a.mu.Unlock()
+	a.cache.Update(a.cfg, a.cfg.ProfileRegionSet(ctx, enabled), cfgs, logs)
+	if a.cache.path != "" {
+		if err := a.cache.Save(); err != nil {
+			a.setError(fmt.Errorf("log group cache: %w", err))
+		}
+	}
+
+	defer a.Redraw()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.logs = a.filterLogGroups(MergeLogGroups(a.logs, logs, cfgs))
+	switch s := a.screen.(type) {
+	case *ChooseLogsScreen:
+		s.SetLogs(a.logs)
+		s.SetStatus(a.ssoStatus())
+	case *LoadingScreen:
+		if progress != s.progress {
+			return a.ShowChooseLogsScreen(ctx)
+		}
+	}
+	return nil
+}
+
+// updateSSOSessions replaces the sessions waiting for login of profiles with
+// sessions.
+func (a *App) updateSSOSessions(profiles []string, sessions []*SSOSession) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	updated := []*SSOSession{}
+	for _, session := range a.ssoSessions {
+		remaining := slices.DeleteFunc(slices.Clone(session.Profiles), func(profile string) bool {
+			return slices.Contains(profiles, profile)
+		})
+		if len(remaining) == 0 {
+			continue
+		}
+		v := *session
+		v.Profiles = remaining
+		updated = append(updated, &v)
+	}
+	for _, session := range sessions {
+		updated = AddSSOSession(updated, session)
+	}
+	a.ssoSessions = updated
+}
+
+func (a *App) ssoStatus() string {
+	if len(a.ssoSessions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d SSO sessions need login (L: login)", len(a.ssoSessions))
+}
+
+func (a *App) ShowSSOLoginScreen(ctx context.Context, sessions []*SSOSession) error {
+	prev := a.screen
+	login := NewSSOLoginScreen(sessions, func(session *SSOSession) {
+		a.discoverProfiles(ctx, session.Profiles)
+	}, func() {
+		switch s := prev.(type) {
+		case *ChooseLogsScreen:
+			s.SetLogs(a.logs)
+			s.SetStatus(a.ssoStatus())
+			a.screen = s
+		case *LoadingScreen:
+			if len(a.logs) > 0 {
+				a.ShowChooseLogsScreen(ctx)
+				return
+			}
+			a.screen = s
+		default:
+			a.screen = prev
+		}
+	})
+	login.redraw = a.Redraw
+	login.theme = a.theme
+	login.keymap = a.keymap
+	a.screen = login
+	a.screen.Init(ctx)
+	return nil
+}
+
+// newLoadProgress returns a progress that adds newly found log groups to the
+// choose screen while discovery is still running.
+func (a *App) newLoadProgress() *LoadProgress {
+	var progress *LoadProgress
+	// shown counts the found log groups added to the choose screen. They are
+	// added as found, and sorted with the others once discovery finishes.
+	shown := 0
+	progress = NewLoadProgress(func() {
+		defer a.Redraw()
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		s, ok := a.screen.(*ChooseLogsScreen)
+		if !ok {
+			return
+		}
+		found := progress.LogGroupsSince(shown)
+		shown += len(found)
+		if len(found) > 0 {
+			a.logs = AppendLogGroups(a.logs, found)
+			s.SetLogs(a.logs)
+		}
+		s.SetStatus("Refreshing... " + progress.Summary())
+	})
+	return progress
+}
+
+// enabledProfiles returns the profiles turned on in the picker. a.mu must be
+// held, as the picker changes them.
+func (a *App) enabledProfiles() []string {
+	return a.profileSelection.Filter(a.profiles)
+}
+
+// filterLogGroups drops log groups of profiles turned off in the picker.
+func (a *App) filterLogGroups(logs []*LogGroup) []*LogGroup {
+	filtered := make([]*LogGroup, 0, len(logs))
+	for _, log := range logs {
+		if a.profileSelection.Enabled(log.Profile()) {
+			filtered = append(filtered, log)
+		}
+	}
+	return filtered
+}
+
+func (a *App) ShowProfilePickerScreen(ctx context.Context) error {
+	picker := NewProfilePickerScreen(a.profiles, a.profileSelection.Disabled, func(disabled []string) {
+		a.profileSelection.Disabled = disabled
+		if err := a.profileSelection.Save(); err != nil {
+			a.err = fmt.Errorf("profile selection: %w", err)
+		}
+		a.logs = a.filterLogGroups(a.logs)
+		a.selected = a.filterLogGroups(a.selected)
+		a.ShowChooseLogsScreen(ctx)
+		go a.RefreshLogGroups(ctx)
+	})
+	picker.theme = a.theme
+	picker.keymap = a.keymap
+	a.screen = picker
+	a.screen.Init(ctx)
+	return nil
+}
+
+// setError shows err in the status bar until the next key press.
+func (a *App) setError(err error) {
+	defer a.Redraw()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.err = err
+}
+
+func (a *App) setChooseStatus(status string) {
+	defer a.Redraw()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if s, ok := a.screen.(*ChooseLogsScreen); ok {
+		s.SetStatus(status)
+	}
 }
 
 func (a *App) ShowChooseLogsScreen(ctx context.Context) error {
-	a.screen = NewChooseLogsScreen(a.logs, a.selected, func(selected []*LogGroup) error {
+	sortLogGroups(a.logs)
+	s := NewChooseLogsScreen(a.logs, a.selected, func(selected []*LogGroup) error {
 		a.selected = selected
 		return a.ShowDisplayLogScreen(ctx, a.selected)
 	})
+	s.theme = a.theme
+	s.keymap = a.keymap
+	s.refresh = func() {
+		go a.RefreshLogGroups(ctx)
+	}
+	s.pickProfiles = func() {
+		a.ShowProfilePickerScreen(ctx)
+	}
+	s.login = func() {
+		if len(a.ssoSessions) > 0 {
+			a.ShowSSOLoginScreen(ctx, a.ssoSessions)
+		}
+	}
+	if a.refreshing.Load() {
+		s.SetStatus("Refreshing...")
+	} else {
+		s.SetStatus(a.ssoStatus())
+	}
+	a.screen = s
 	a.screen.Init(ctx)
 	return nil
 }
 
 func (a *App) ShowDisplayLogScreen(ctx context.Context, logs []*LogGroup) error {
-	a.screen = NewDisplayLogScreen(logs, func(logs []*LogGroup) {
+	display := NewDisplayLogScreen(logs, func(logs []*LogGroup) {
 		a.ShowChooseLogsScreen(ctx)
 	})
+	display.redraw = a.Redraw
+	display.theme = a.theme
+	display.keymap = a.keymap
+	display.wrap = a.cfg != nil && a.cfg.Wrap
+	display.location = a.location
+	display.correlator = a.correlator
+	display.multiline = a.multiline
+	display.fold = a.cfg == nil || !a.cfg.Multiline.Disabled
+	display.follow = func(ids []string, events []timelineEvent) {
+		switch len(ids) {
+		case 0:
+			a.err = errors.New("no trace or request ID in the event")
+		case 1:
+			a.ShowTimelineScreen(ctx, display, ids[0], events)
+		default:
+			// Tab cycles through the IDs, and enter follows the shown one.
+			a.command = &commandLine{text: "follow " + ids[0], prefix: "follow ", matches: ids}
+		}
+	}
+	a.screen = display
+	a.screen.Init(ctx)
+	return nil
+}
+
+// ShowTimelineScreen lists events, the events of the groups of display that
+// contain id. Going back, or opening an event, returns to display.
+func (a *App) ShowTimelineScreen(ctx context.Context, display *DisplayLogScreen, id string, events []timelineEvent) error {
+	back := func() {
+		a.screen = display
+		rows, cols, _, _, _ := a.renderer.Size()
+		display.Resize(ctx, rows, cols)
+	}
+	timeline := NewTimelineScreen(id, display.logs, events, func(log *LogGroup, evt *LogEvent) {
+		display.Reveal(log, evt)
+		back()
+	}, back)
+	timeline.redraw = a.Redraw
+	timeline.theme = a.theme
+	timeline.keymap = a.keymap
+	timeline.location = a.location
+	a.screen = timeline
 	a.screen.Init(ctx)
 	return nil
 }
@@ -81,22 +527,139 @@ func (a *App) render(ctx context.Context) error {
 		return nil
 	}
 
-	if err := a.screen.Render(ctx, a.tty); err != nil {
+	if err := a.renderer.Begin(); err != nil {
 		return err
 	}
-	return nil
+	if err := a.screen.Render(ctx, a.renderer); err != nil {
+		return err
+	}
+	_, cols, _, _, _ := a.renderer.Size()
+	footer := ""
+	if a.command != nil {
+		footer = a.commandBar(cols)
+	} else {
+		footer = a.statusBar(cols)
+	}
+	if err := a.renderer.Footer(footer); err != nil {
+		return err
+	}
+	if a.help {
+		a.renderer.Overlay("Help", a.helpLines())
+	} else {
+		a.renderer.Overlay("", nil)
+	}
+	return a.renderer.Flush()
 }
 
-func (a *App) handleInput(ctx context.Context, r rune) (bool, error) {
-	return a.screen.HandleInput(ctx, r)
+// statusBar returns the status of the screen as a line of cols columns.
+func (a *App) statusBar(cols int) string {
+	status := a.screen.Status()
+	parts := []string{}
+	for _, part := range []string{status.Mode, status.Live} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if status.Total > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d", status.Index, status.Total))
+	}
+	if status.Capacity > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d events", status.Buffered, status.Capacity))
+	}
+	left := " " + strings.Join(parts, " | ") + " "
+
+	right := ""
+	if keys := a.keymap.Keys(ActionHelp); len(keys) > 0 && slices.Contains(a.screen.Actions(), ActionHelp) {
+		right = fmt.Sprintf(" %s: help ", keys[0])
+	}
+
+	err := status.Err
+	if err == nil {
+		err = a.err
+	}
+	message := ""
+	if err != nil {
+		message = strings.ReplaceAll(err.Error(), "\n", " ")
+		message = Truncate(" "+message+" ", max(cols-StringWidth(left)-StringWidth(right), 0), "… ")
+	}
+
+	pad := strings.Repeat(" ", max(cols-StringWidth(left)-StringWidth(message)-StringWidth(right), 0))
+	bar := a.theme.Selected
+	return bar.Paint(left) + bar.Merge(a.theme.Error).Paint(message) + bar.Paint(pad+right)
 }
 
-func (a *App) handleCtrl(ctx context.Context, ctrl string) (bool, error) {
-	return a.screen.HandleCtrl(ctx, ctrl)
+// helpLines lists the keys of the actions the screen handles now.
+func (a *App) helpLines() []string {
+	type entry struct {
+		keys  string
+		label string
+	}
+	entries := []entry{}
+	width := 0
+	for _, action := range a.screen.Actions() {
+		keys := a.keymap.Keys(action)
+		if len(keys) == 0 {
+			continue
+		}
+		e := entry{keys: strings.Join(keys, ", "), label: actionLabels[action]}
+		entries = append(entries, e)
+		width = max(width, StringWidth(e.keys))
+	}
+	entries = append(entries, entry{keys: "ctrl+c", label: "quit"})
+	width = max(width, len("ctrl+c"))
+
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.keys + strings.Repeat(" ", width-StringWidth(e.keys)+2) + e.label
+	}
+	if slices.Contains(a.screen.Actions(), ActionCommand) {
+		lines = append(lines, "")
+		for _, c := range a.commands() {
+			lines = append(lines, ":"+c.usage)
+		}
+	}
+	return lines
 }
 
-func (a *App) handleMouse(ctx context.Context, code, x, y int) (bool, error) {
-	return a.screen.HandleMouse(ctx, code, x, y)
+func (a *App) handleEvent(ctx context.Context, evt Event) (bool, error) {
+	switch evt := evt.(type) {
+	case KeyEvent:
+		// Any key dismisses the error, and closes the help overlay.
+		a.err = nil
+		if a.help {
+			a.help = false
+			return true, nil
+		}
+		if a.command != nil {
+			return a.handleCommandKey(ctx, evt)
+		}
+		actions := slices.DeleteFunc(slices.Clone(a.screen.Actions()), func(action Action) bool {
+			return action != ActionHelp && action != ActionCommand
+		})
+		switch action, _ := a.keys.Read(a.keymap, actions, evt); action {
+		case ActionHelp:
+			a.help = true
+			return true, nil
+		case ActionCommand:
+			a.command = &commandLine{}
+			return true, nil
+		}
+		return a.screen.HandleKey(ctx, evt)
+	case MouseEvent:
+		if evt.Release || evt.Motion {
+			return a.screen.HandleMouse(ctx, evt)
+		}
+		if a.help {
+			a.help = false
+			return true, nil
+		}
+		// Presses on the status bar are not for the screen.
+		if rows, _, _, _, _ := a.renderer.Size(); evt.Y > rows {
+			return true, nil
+		}
+		return a.screen.HandleMouse(ctx, evt)
+	}
+	return true, nil
 }
 
 func (a *App) Start(ctx context.Context) error {
@@ -151,95 +714,49 @@ func (a *App) Start(ctx context.Context) error {
 	}()
 
 	go func() {
-		defer func() {
-			a.ForceUnlock()
-		}()
+		defer cancel()
 
-		ctrl := false
-		ctrlCode := ""
+		input := NewInputDecoder(a.tty)
 		for !quit {
-			r, err := a.tty.Rune()
-			a.mu.Lock()
+			evt, err := input.Next(ctx)
 			if err != nil {
-				a.mu.Unlock()
 				return
 			}
 
-			if string(r) == "\x1b" {
-				ctrl = true
-				chars := make([]byte, 2)
-				_, err := a.tty.Read(chars)
-				if err != nil {
-					a.mu.Unlock()
-					return
-				}
-				ctrlCode = string(chars)
-				r = rune(chars[1])
-			}
-
-			if ctrl {
-				ctrl = false
-				if 'A' <= r && r <= 'D' || r == 'H' {
-					handled, err := a.handleCtrl(ctx, "\x1b"+ctrlCode)
-					if err != nil {
-						a.mu.Unlock()
-						return
-					}
-					if !handled {
-						a.mu.Unlock()
-						return
-					}
-				} else if r == 'M' {
-					// load mouse information 3bytes
-					buf := make([]byte, 3)
-					_, err := a.tty.Read(buf)
-					if err != nil {
-						a.mu.Unlock()
-						return
-					}
-
-					handled, err := a.handleMouse(ctx, int(buf[0])-32, int(buf[1])-32, int(buf[2])-32)
-					if err != nil {
-						a.mu.Unlock()
-						return
-					}
-					if !handled {
-						a.mu.Unlock()
-						return
-					}
-				}
-				ctrlCode = ""
-				a.mu.Unlock()
-				continue
-			}
-
 			// Ctrl+C
-			if r == 3 {
-				a.mu.Unlock()
+			if key, ok := evt.(KeyEvent); ok && key.Key == KeyRune && key.Mod == ModCtrl && key.Rune == 'c' {
 				return
 			}
 
-			handled, err := a.handleInput(ctx, r)
+			a.mu.Lock()
+			handled, err := a.handleEvent(ctx, evt)
 			a.mu.Unlock()
-			if err != nil {
-				return
-			}
-			if !handled {
+			if err != nil || !handled {
 				return
 			}
+			a.Redraw()
+		}
+	}()
 
+	go func() {
+		for {
 			select {
 			case <-ctx.Done():
 				return
-			default:
+			case <-a.tty.Resized():
+			}
+			rows, cols, _, _, err := a.tty.Size()
+			if err != nil {
 				continue
 			}
+			a.mu.Lock()
+			a.renderer.Invalidate()
+			a.screen.Resize(ctx, rows-statusRows, cols)
+			a.mu.Unlock()
+			a.Redraw()
 		}
 	}()
 
-	ticker := time.NewTicker(time.Second / fps)
-	// ticker := time.NewTicker(time.Second * 3)
-	defer ticker.Stop()
 	for {
 		a.mu.Lock()
 		a.render(ctx)
@@ -247,8 +764,13 @@ func (a *App) Start(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 			return nil
-		case <-ticker.C:
-			continue
+		case <-a.redraw:
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second / fps):
 		}
 	}
 }
@@ -267,7 +789,7 @@ func (a *App) Close() error {
 }
 
 func (a *App) Opened() bool {
-	return a.tty.t != nil
+	return a.tty.Opened()
 }
 
 func (a *App) ForceUnlock() {
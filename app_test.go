@@ -0,0 +1,105 @@
+package cwl
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestAppLoginDuringRefresh(t *testing.T) {
+	// Run the discoveries in parallel even on a single CPU, so that the race
+	// detector sees their cache updates overlap.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	setupSharedConfig(t)
+	ctx := context.Background()
+	cache, err := LoadLogGroupCache(filepath.Join(t.TempDir(), LogGroupCacheFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// gate holds the discoveries of a round until all of them described
+	// their regions, so that they update the cache at once.
+	var gate sync.WaitGroup
+	a := NewApp(WithTerminal(NewVirtualTerminal(24, 80)))
+	a.cfg = &Config{
+		Regions: []string{"us-east-1", "eu-west-1"},
+		newClient: func(profile string, awscfg aws.Config) Client {
+			gate.Done()
+			gate.Wait()
+			return NewFakeClient(fakeLogGroups("111111111111", awscfg.Region, 3)...)
+		},
+	}
+	a.cache = cache
+	a.profiles = []string{"dev", "sso"}
+	a.profileSelection = &ProfileSelection{}
+
+	session := &SSOSession{Key: "corp", Name: "corp", Profiles: []string{"dev"}}
+	a.mu.Lock()
+	a.ShowChooseLogsScreen(ctx)
+	a.ShowSSOLoginScreen(ctx, []*SSOSession{session})
+	login := a.screen.(*SSOLoginScreen)
+	a.mu.Unlock()
+
+	// Logins complete while background refreshes run and the profile picker
+	// changes the selection.
+	done := make(chan struct{})
+	picker := make(chan struct{})
+	go func() {
+		defer close(picker)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			a.mu.Lock()
+			if i%2 == 0 {
+				a.profileSelection.Disabled = []string{"sso"}
+			} else {
+				a.profileSelection.Disabled = nil
+			}
+			a.mu.Unlock()
+		}
+	}()
+	for range 10 {
+		// A refresh and two logins each discover dev in both regions.
+		gate.Add(3 * len(a.cfg.Regions))
+		wg := sync.WaitGroup{}
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			a.RefreshLogGroups(ctx)
+		}()
+		for range 2 {
+			go func() {
+				defer wg.Done()
+				login.done(session)
+			}()
+		}
+		wg.Wait()
+	}
+	close(done)
+	<-picker
+
+	cache, err = LoadLogGroupCache(cache.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, region := range a.cfg.Regions {
+		entry, ok := cache.Entries[logGroupCacheKey("dev", region)]
+		if !ok {
+			t.Errorf("no cache entry of dev/%s", region)
+			continue
+		}
+		if len(entry.LogGroups) != 3 {
+			t.Errorf("dev/%s: cached %d log groups, want 3", region, len(entry.LogGroups))
+		}
+	}
+	if len(a.logs) != 6 {
+		t.Errorf("shown %d log groups, want 6", len(a.logs))
+	}
+}
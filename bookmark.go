@@ -0,0 +1,162 @@
+package cwl
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// bookmark marks an event of a group, with an optional note. It holds the
+// event itself, so that it outlives the buffer that trims the event.
+type bookmark struct {
+	log   *LogGroup
+	event *LogEvent
+	note  string
+}
+
+// Bookmarks panels are at most maxPanelWidth columns wide, and are only shown
+// when minPaneWidth columns are left beside them.
+const (
+	maxPanelWidth = 48
+	minPaneWidth  = 40
+)
+
+// bookmarkIndex returns the index of the bookmark of evt, or -1.
+func (s *DisplayLogScreen) bookmarkIndex(evt *LogEvent) int {
+	return slices.IndexFunc(s.bookmarks, func(b *bookmark) bool {
+		return b.event == evt
+	})
+}
+
+// addBookmark marks evt of log, keeping the bookmarks in time order.
+func (s *DisplayLogScreen) addBookmark(log *LogGroup, evt *LogEvent, note string) {
+	if i := s.bookmarkIndex(evt); i >= 0 {
+		s.bookmarks[i].note = note
+		return
+	}
+	i, _ := slices.BinarySearchFunc(s.bookmarks, evt.timestamp, func(b *bookmark, t time.Time) int {
+		if b.event.timestamp.After(t) {
+			return 1
+		}
+		return -1
+	})
+	s.bookmarks = slices.Insert(s.bookmarks, i, &bookmark{log: log, event: evt, note: note})
+}
+
+// current returns the event under the cursor of the focused group.
+func (s *DisplayLogScreen) current() *LogEvent {
+	events := s.buffers[s.log.ARN()]
+	if idx := s.index[s.log.ARN()]; 0 <= idx && idx < len(events) {
+		return events[idx]
+	}
+	return nil
+}
+
+// toggleBookmark marks or unmarks the event under the cursor.
+func (s *DisplayLogScreen) toggleBookmark() {
+	evt := s.current()
+	if evt == nil {
+		return
+	}
+	if i := s.bookmarkIndex(evt); i >= 0 {
+		s.bookmarks = slices.Delete(s.bookmarks, i, i+1)
+	} else {
+		s.addBookmark(s.log, evt, "")
+	}
+	s.changed[s.log.ARN()] = true
+}
+
+// Bookmark marks the event under the cursor with note, replacing the note of
+// an existing bookmark.
+func (s *DisplayLogScreen) Bookmark(note string) error {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	evt := s.current()
+	if evt == nil {
+		return errors.New("no event to bookmark")
+	}
+	s.addBookmark(s.log, evt, note)
+	s.changed[s.log.ARN()] = true
+	return nil
+}
+
+// stepBookmark moves the cursor to the next bookmark in time, in any group,
+// or to the previous one if step is negative.
+func (s *DisplayLogScreen) stepBookmark(step int) {
+	if len(s.bookmarks) == 0 {
+		return
+	}
+	evt := s.current()
+	i := -1
+	switch {
+	case evt == nil && step > 0:
+		i = 0
+	case evt == nil:
+		i = len(s.bookmarks) - 1
+	case s.bookmarkIndex(evt) >= 0:
+		i = s.bookmarkIndex(evt) + step
+	case step > 0:
+		i = slices.IndexFunc(s.bookmarks, func(b *bookmark) bool {
+			return b.event.timestamp.After(evt.timestamp)
+		})
+	default:
+		for j, b := range s.bookmarks {
+			if b.event.timestamp.Before(evt.timestamp) {
+				i = j
+			}
+		}
+	}
+	if 0 <= i && i < len(s.bookmarks) {
+		s.jumpToEvent(s.bookmarks[i].log, s.bookmarks[i].event)
+	}
+}
+
+// panelWidth returns the columns of the bookmarks panel, divider included,
+// or 0 if it is hidden.
+func (s *DisplayLogScreen) panelWidth() int {
+	if !s.panel {
+		return 0
+	}
+	width := min(s.col/3, maxPanelWidth)
+	if s.col-width < minPaneWidth {
+		return 0
+	}
+	return width
+}
+
+// renderPanel draws the bookmarks panel, which takes the whole of tty. The
+// bookmark under the cursor is kept in view.
+func (s *DisplayLogScreen) renderPanel(tty Terminal, rows, cols int) {
+	tty.WriteString("%s", s.theme.Header.Paint(Truncate(fmt.Sprintf(" Bookmarks (%d)", len(s.bookmarks)), cols, "...")))
+	current := s.bookmarkIndex(s.current())
+	top := max(current-(rows-2), 0)
+	s.panelTop = top
+	for i := top; i < len(s.bookmarks) && i-top < rows-1; i++ {
+		b := s.bookmarks[i]
+		timestamp := b.event.Timestamp()
+		if s.location != nil {
+			timestamp = timestamp.In(s.location)
+		}
+		text := b.note
+		if text == "" {
+			text = printable(strings.ReplaceAll(b.event.Message(), "\n", " "))
+		}
+		tsStyle, textStyle := s.theme.Timestamp, s.theme.Message
+		if i == current {
+			tsStyle = tsStyle.Merge(s.theme.Selected)
+			textStyle = textStyle.Merge(s.theme.Selected)
+		}
+		tty.NextLine(1)
+		ts := timestamp.Format(time.TimeOnly) + " "
+		tty.WriteString("%s%s", tsStyle.Paint(ts), textStyle.Paint(Truncate(b.log.Name()+" "+text, cols-len(ts), "...")))
+	}
+}
+
+// clickPanel jumps to the bookmark at row y of the panel.
+func (s *DisplayLogScreen) clickPanel(y int) {
+	if i := s.panelTop + y - 2; y >= 2 && i < len(s.bookmarks) {
+		s.jumpToEvent(s.bookmarks[i].log, s.bookmarks[i].event)
+	}
+}
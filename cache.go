@@ -0,0 +1,241 @@
+package cwl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+const (
+	CacheDir             = "cwl"
+	LogGroupCacheFile    = "loggroups.json"
+	ProfileSelectionFile = "profiles.json"
+)
+
+type LogGroupCacheEntry struct {
+	Profile     string `json:"profile"`
+	Region      string `json:"region"`
+	EndpointURL string `json:"endpointURL,omitempty"`
+	// MonitoringAccountID is the account the log groups were discovered
+	// through, if linked accounts were included.
+	MonitoringAccountID string           `json:"monitoringAccountID,omitempty"`
+	UpdatedAt           time.Time        `json:"updatedAt"`
+	LogGroups           []types.LogGroup `json:"logGroups"`
+}
+
+// LogGroupCache is safe for concurrent use, as discoveries started by a
+// refresh and by an SSO login can finish at the same time.
+type LogGroupCache struct {
+	mu      sync.Mutex
+	path    string
+	Entries map[string]*LogGroupCacheEntry `json:"entries"`
+}
+
+func logGroupCacheKey(profile, region string) string {
+	return profile + "/" + region
+}
+
+func LoadDefaultLogGroupCache() (*LogGroupCache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return LoadLogGroupCache(filepath.Join(dir, CacheDir, LogGroupCacheFile))
+}
+
+// LoadLogGroupCache reads the cache at path. A missing file yields an empty
+// cache that will be created on Save.
+func LoadLogGroupCache(path string) (*LogGroupCache, error) {
+	c := &LogGroupCache{
+		path:    path,
+		Entries: make(map[string]*LogGroupCacheEntry),
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(c); err != nil {
+		return nil, err
+	}
+	if c.Entries == nil {
+		c.Entries = make(map[string]*LogGroupCacheEntry)
+	}
+	return c, nil
+}
+
+func (c *LogGroupCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Put stores the log groups of profile in region. monitoringAccountID is the
+// account they were discovered through, if linked accounts were included.
+func (c *LogGroupCache) Put(profile, region, endpointURL, monitoringAccountID string, logGroups []types.LogGroup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.put(profile, region, endpointURL, monitoringAccountID, logGroups)
+}
+
+func (c *LogGroupCache) put(profile, region, endpointURL, monitoringAccountID string, logGroups []types.LogGroup) {
+	c.Entries[logGroupCacheKey(profile, region)] = &LogGroupCacheEntry{
+		Profile:             profile,
+		Region:              region,
+		EndpointURL:         endpointURL,
+		MonitoringAccountID: monitoringAccountID,
+		UpdatedAt:           time.Now(),
+		LogGroups:           logGroups,
+	}
+}
+
+// Update stores the discovered log groups of every loaded profile region,
+// including those without any log groups, and drops the entries of profile
+// regions other than regions, such as the ones removed from the config.
+func (c *LogGroupCache) Update(cfg *Config, regions []ProfileRegion, cfgs map[ProfileRegion]aws.Config, logGroups []*LogGroup) {
+	m := make(map[ProfileRegion][]types.LogGroup, len(cfgs))
+	via := make(map[ProfileRegion]string, len(cfgs))
+	for _, logGroup := range logGroups {
+		key := ProfileRegion{Profile: logGroup.Profile(), Region: logGroup.Region()}
+		m[key] = append(m[key], logGroup.LogGroup)
+		via[key] = logGroup.MonitoringAccountID()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range cfgs {
+		c.put(key.Profile, key.Region, cfg.ProfileEndpointURL(key.Profile), via[key], m[key])
+	}
+	for key, entry := range c.Entries {
+		if !slices.Contains(regions, ProfileRegion{Profile: entry.Profile, Region: entry.Region}) {
+			delete(c.Entries, key)
+		}
+	}
+}
+
+// Expired reports whether any of regions has no entry or an entry older than
+// ttl, so that a region newly added to a profile is discovered right away.
+func (c *LogGroupCache) Expired(regions []ProfileRegion, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, region := range regions {
+		entry, ok := c.Entries[logGroupCacheKey(region.Profile, region.Region)]
+		if !ok || time.Since(entry.UpdatedAt) > ttl {
+			return true
+		}
+	}
+	return false
+}
+
+// entries returns the entries of profiles.
+func (c *LogGroupCache) entries(profiles []string) []*LogGroupCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := []*LogGroupCacheEntry{}
+	for _, entry := range c.Entries {
+		if slices.Contains(profiles, entry.Profile) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ProfileSelection remembers the profiles turned off in the profile picker.
+// Profiles it does not know about are enabled.
+type ProfileSelection struct {
+	path     string
+	saved    bool
+	Disabled []string `json:"disabled"`
+}
+
+func LoadDefaultProfileSelection() (*ProfileSelection, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return LoadProfileSelection(filepath.Join(dir, CacheDir, ProfileSelectionFile))
+}
+
+func LoadProfileSelection(path string) (*ProfileSelection, error) {
+	p := &ProfileSelection{path: path}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(p); err != nil {
+		return nil, err
+	}
+	p.saved = true
+	return p, nil
+}
+
+// Saved reports whether a choice has been made in the profile picker before.
+func (p *ProfileSelection) Saved() bool {
+	return p.saved
+}
+
+func (p *ProfileSelection) Enabled(profile string) bool {
+	return !slices.Contains(p.Disabled, profile)
+}
+
+// Filter returns the enabled profiles.
+func (p *ProfileSelection) Filter(profiles []string) []string {
+	enabled := []string{}
+	for _, profile := range profiles {
+		if p.Enabled(profile) {
+			enabled = append(enabled, profile)
+		}
+	}
+	return enabled
+}
+
+func (p *ProfileSelection) Save() error {
+	if p.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(p); err != nil {
+		return err
+	}
+	p.saved = true
+	return nil
+}
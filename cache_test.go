@@ -0,0 +1,133 @@
+package cwl
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestConfigProfileRegionSet(t *testing.T) {
+	setupSharedConfig(t)
+	tests := []struct {
+		name     string
+		cfg      *Config
+		profiles []string
+		want     []ProfileRegion
+	}{
+		{
+			name:     "region of the profile",
+			cfg:      &Config{},
+			profiles: []string{"dev", "sso"},
+			want:     []ProfileRegion{{"dev", "us-east-1"}, {"sso", "us-east-1"}},
+		},
+		{
+			name: "configured regions",
+			cfg: &Config{
+				Regions:  []string{"us-east-1", "eu-west-1"},
+				Profiles: map[string]*ProfileConfig{"sso": {Regions: []string{"ap-northeast-1"}}},
+			},
+			profiles: []string{"dev", "sso"},
+			want:     []ProfileRegion{{"dev", "us-east-1"}, {"dev", "eu-west-1"}, {"sso", "ap-northeast-1"}},
+		},
+		{
+			name:     "unknown profile",
+			cfg:      &Config{},
+			profiles: []string{"dev", "unknown"},
+			want:     []ProfileRegion{{"dev", "us-east-1"}},
+		},
+		{
+			name:     "no region",
+			cfg:      &Config{},
+			profiles: []string{EnvironmentProfile},
+			want:     []ProfileRegion{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.cfg.ProfileRegionSet(context.Background(), tt.profiles)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogGroupCacheExpired(t *testing.T) {
+	regions := []ProfileRegion{{"dev", "us-east-1"}, {"dev", "eu-west-1"}}
+	tests := []struct {
+		name    string
+		entries map[ProfileRegion]time.Duration
+		want    bool
+	}{
+		{
+			name:    "fresh",
+			entries: map[ProfileRegion]time.Duration{regions[0]: 0, regions[1]: 0},
+		},
+		{
+			name:    "stale region",
+			entries: map[ProfileRegion]time.Duration{regions[0]: 0, regions[1]: 2 * time.Hour},
+			want:    true,
+		},
+		{
+			name:    "region added",
+			entries: map[ProfileRegion]time.Duration{regions[0]: 0},
+			want:    true,
+		},
+		{
+			name:    "stale region removed",
+			entries: map[ProfileRegion]time.Duration{regions[0]: 0, regions[1]: 0, {"dev", "ap-northeast-1"}: 2 * time.Hour},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := LoadLogGroupCache(filepath.Join(t.TempDir(), LogGroupCacheFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, age := range tt.entries {
+				cache.Put(key.Profile, key.Region, "", "", nil)
+				cache.Entries[logGroupCacheKey(key.Profile, key.Region)].UpdatedAt = time.Now().Add(-age)
+			}
+			if got := cache.Expired(regions, time.Hour); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogGroupCacheUpdate(t *testing.T) {
+	cache, err := LoadLogGroupCache(filepath.Join(t.TempDir(), LogGroupCacheFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []ProfileRegion{{"dev", "us-east-1"}, {"dev", "eu-west-1"}, {"dev", "ap-northeast-1"}, {"prod", "us-east-1"}} {
+		cache.Put(key.Profile, key.Region, "", "", nil)
+	}
+
+	// dev is discovered in us-east-1 only, after ap-northeast-1 was removed
+	// from its regions and prod was turned off.
+	client := NewFakeClient()
+	logs := []*LogGroup{}
+	for _, logGroup := range fakeLogGroups("111111111111", "us-east-1", 2) {
+		logs = append(logs, mustLogGroup(t, client, "dev", "", logGroup))
+	}
+	regions := []ProfileRegion{{"dev", "us-east-1"}, {"dev", "eu-west-1"}}
+	cfgs := map[ProfileRegion]aws.Config{regions[0]: {Region: "us-east-1"}}
+	cache.Update(&Config{}, regions, cfgs, logs)
+
+	keys := []string{}
+	for key := range cache.Entries {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	if want := []string{"dev/eu-west-1", "dev/us-east-1"}; !slices.Equal(keys, want) {
+		t.Errorf("cached %v, want %v", keys, want)
+	}
+	if n := len(cache.Entries["dev/us-east-1"].LogGroups); n != 2 {
+		t.Errorf("cached %d log groups of dev/us-east-1, want 2", n)
+	}
+}
@@ -0,0 +1,31 @@
+package cwl
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+// Client is the part of the CloudWatch Logs API cwl uses. NewClient adapts
+// the SDK client to it, and FakeClient implements it in memory.
+type Client interface {
+	DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error)
+	FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error)
+	LiveTail(ctx context.Context, params *cloudwatchlogs.StartLiveTailInput) (*cloudwatchlogs.StartLiveTailEventStream, error)
+}
+
+type sdkClient struct {
+	*cloudwatchlogs.Client
+}
+
+func NewClient(client *cloudwatchlogs.Client) Client {
+	return &sdkClient{Client: client}
+}
+
+func (c *sdkClient) LiveTail(ctx context.Context, params *cloudwatchlogs.StartLiveTailInput) (*cloudwatchlogs.StartLiveTailEventStream, error) {
+	output, err := c.StartLiveTail(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return output.GetStream(), nil
+}
@@ -2,16 +2,33 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"os"
 
 	"github.com/ralsnet/go-cwl"
 )
 
 func main() {
+	endpointURL := flag.String("endpoint-url", "", "CloudWatch Logs endpoint URL, e.g. http://localhost:4566 for LocalStack")
+	record := flag.String("record", "", "write all terminal output to this file for debugging")
+	flag.Parse()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	app := cwl.NewApp()
+	opts := []cwl.Option{cwl.WithEndpointURL(*endpointURL)}
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		opts = append(opts, cwl.WithRecorder(f))
+	}
+
+	app := cwl.NewApp(opts...)
 	defer func() {
 		if err := recover(); err != nil {
 			fmt.Println(err)
@@ -0,0 +1,318 @@
+package cwl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// errQuit is returned by the quit command to end the app.
+var errQuit = errors.New("quit")
+
+// command is a command of the command line opened with ':'.
+type command struct {
+	name  string
+	usage string
+	// args returns the candidates tab completes the argument with.
+	args func(a *App) []string
+	run  func(ctx context.Context, a *App, arg string) error
+}
+
+// commands returns the commands of the command line. They act on the
+// current screen and fail on screens they do not apply to.
+func (a *App) commands() []command {
+	return []command{
+		{
+			name:  "filter",
+			usage: "filter [pattern]",
+			run: func(ctx context.Context, a *App, arg string) error {
+				switch s := a.screen.(type) {
+				case *ChooseLogsScreen:
+					s.SetFilter(arg)
+				case *DisplayLogScreen:
+					s.SetFilter(ctx, arg)
+				default:
+					return errNotHere("filter")
+				}
+				return nil
+			},
+		},
+		{
+			name:  "since",
+			usage: "since <duration>",
+			run: func(ctx context.Context, a *App, arg string) error {
+				s, ok := a.screen.(*DisplayLogScreen)
+				if !ok {
+					return errNotHere("since")
+				}
+				d, err := time.ParseDuration(arg)
+				if err != nil {
+					return fmt.Errorf("since: %w", err)
+				}
+				s.Backfill(ctx, time.Now().Add(-d))
+				return nil
+			},
+		},
+		{
+			name:  "export",
+			usage: "export <file>",
+			run: func(ctx context.Context, a *App, arg string) error {
+				s, ok := a.screen.(*DisplayLogScreen)
+				if !ok {
+					return errNotHere("export")
+				}
+				if arg == "" {
+					return errors.New("export: missing file name")
+				}
+				if _, err := s.Export(arg); err != nil {
+					return fmt.Errorf("export: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			name:  "mark",
+			usage: "mark [note]",
+			run: func(ctx context.Context, a *App, arg string) error {
+				s, ok := a.screen.(*DisplayLogScreen)
+				if !ok {
+					return errNotHere("mark")
+				}
+				return s.Bookmark(arg)
+			},
+		},
+		{
+			name:  "follow",
+			usage: "follow [id]",
+			args: func(a *App) []string {
+				if s, ok := a.screen.(*DisplayLogScreen); ok {
+					return s.IDs()
+				}
+				return nil
+			},
+			run: func(ctx context.Context, a *App, arg string) error {
+				s, ok := a.screen.(*DisplayLogScreen)
+				if !ok {
+					return errNotHere("follow")
+				}
+				s.Follow(arg)
+				return nil
+			},
+		},
+		{
+			name:  "group",
+			usage: "group <name>",
+			args: func(a *App) []string {
+				logs := a.logs
+				if s, ok := a.screen.(*DisplayLogScreen); ok {
+					logs = s.logs
+				}
+				names := make([]string, len(logs))
+				for i, log := range logs {
+					names[i] = log.Name()
+				}
+				return names
+			},
+			run: func(ctx context.Context, a *App, arg string) error {
+				switch s := a.screen.(type) {
+				case *ChooseLogsScreen:
+					return s.Jump(arg)
+				case *DisplayLogScreen:
+					return s.ShowGroup(arg)
+				}
+				return errNotHere("group")
+			},
+		},
+		{
+			name:  "tz",
+			usage: "tz <zone>",
+			args: func(a *App) []string {
+				return []string{"Local", "UTC"}
+			},
+			run: func(ctx context.Context, a *App, arg string) error {
+				loc, err := time.LoadLocation(arg)
+				if err != nil {
+					return fmt.Errorf("tz: %w", err)
+				}
+				a.location = loc
+				if s, ok := a.screen.(*DisplayLogScreen); ok {
+					s.SetLocation(loc)
+				}
+				return nil
+			},
+		},
+		{
+			name:  "set",
+			usage: "set [no]<option>",
+			args: func(a *App) []string {
+				return []string{"wrap", "nowrap", "live", "nolive", "fold", "nofold"}
+			},
+			run: func(ctx context.Context, a *App, arg string) error {
+				s, ok := a.screen.(*DisplayLogScreen)
+				if !ok {
+					return errNotHere("set")
+				}
+				return s.Set(arg)
+			},
+		},
+		{
+			name:  "quit",
+			usage: "quit",
+			run: func(ctx context.Context, a *App, arg string) error {
+				return errQuit
+			},
+		},
+	}
+}
+
+func errNotHere(name string) error {
+	return fmt.Errorf("%s: not available on this screen", name)
+}
+
+// findCommand returns the command named name, or the only one it is a prefix
+// of, so that ":q" quits.
+func (a *App) findCommand(name string) (command, error) {
+	found := []command{}
+	for _, c := range a.commands() {
+		if c.name == name {
+			return c, nil
+		}
+		if strings.HasPrefix(c.name, name) {
+			found = append(found, c)
+		}
+	}
+	if len(found) != 1 || name == "" {
+		return command{}, fmt.Errorf("unknown command: %s", name)
+	}
+	return found[0], nil
+}
+
+// commandLine is the line typed after ':'.
+type commandLine struct {
+	text string
+	// matches are the completions tab cycles through, replacing the word
+	// after prefix.
+	matches []string
+	match   int
+	prefix  string
+}
+
+// runCommand runs line and reports whether the app goes on. Its error, or
+// nil when it succeeds, replaces the error of the status bar.
+func (a *App) runCommand(ctx context.Context, line string) bool {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if name == "" {
+		return true
+	}
+	c, err := a.findCommand(name)
+	if err == nil {
+		err = c.run(ctx, a, strings.TrimSpace(arg))
+	}
+	if errors.Is(err, errQuit) {
+		return false
+	}
+	a.err = err
+	return true
+}
+
+func (a *App) handleCommandKey(ctx context.Context, key KeyEvent) (bool, error) {
+	c := a.command
+	if key.Key != KeyTab && key.Key != KeyBacktab {
+		c.matches = nil
+	}
+	switch {
+	case key.Key == KeyEnter:
+		a.command = nil
+		return a.runCommand(ctx, c.text), nil
+	case key.Key == KeyEscape:
+		a.command = nil
+	case key.Key == KeyBackspace:
+		text := []rune(c.text)
+		if len(text) == 0 {
+			a.command = nil
+			return true, nil
+		}
+		c.text = string(text[:len(text)-1])
+	case key.Key == KeyTab:
+		a.complete(1)
+	case key.Key == KeyBacktab:
+		a.complete(-1)
+	case key.Key == KeyPaste:
+		c.text += strings.Map(func(r rune) rune {
+			if unicode.IsPrint(r) {
+				return r
+			}
+			return -1
+		}, key.Text)
+	case key.Key == KeyRune && key.Mod&(ModCtrl|ModAlt) == 0:
+		if unicode.IsPrint(key.Rune) {
+			c.text += string(key.Rune)
+		}
+	}
+	return true, nil
+}
+
+// complete replaces the word being typed with the next of its completions,
+// or the previous one if step is negative. Command names complete by prefix
+// and arguments by any part of them.
+func (a *App) complete(step int) {
+	c := a.command
+	if c.matches == nil {
+		name, arg, hasArg := strings.Cut(c.text, " ")
+		candidates := []string{}
+		word := name
+		if !hasArg {
+			c.prefix = ""
+			for _, cmd := range a.commands() {
+				if strings.HasPrefix(cmd.name, name) {
+					candidates = append(candidates, cmd.name)
+				}
+			}
+		} else {
+			cmd, err := a.findCommand(name)
+			if err != nil || cmd.args == nil {
+				return
+			}
+			c.prefix = cmd.name + " "
+			word = strings.TrimLeft(arg, " ")
+			for _, candidate := range cmd.args(a) {
+				if strings.Contains(strings.ToLower(candidate), strings.ToLower(word)) {
+					candidates = append(candidates, candidate)
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			return
+		}
+		// Typing on from the only completion of a name starts its argument.
+		if !hasArg && len(candidates) == 1 {
+			c.text = candidates[0] + " "
+			return
+		}
+		c.matches = candidates
+		c.match = -1
+		if step < 0 {
+			c.match = 0
+		}
+	}
+	c.match = (c.match + step + len(c.matches)) % len(c.matches)
+	c.text = c.prefix + c.matches[c.match]
+}
+
+// commandBar returns the command line as a line of cols columns.
+func (a *App) commandBar(cols int) string {
+	c := a.command
+	count := ""
+	if len(c.matches) > 1 {
+		count = fmt.Sprintf("  (%d/%d)", c.match+1, len(c.matches))
+	}
+	// Keep the end of a long line, where the cursor is, in view.
+	line := []rune(":" + c.text)
+	for len(line) > 0 && StringWidth(string(line))+1+StringWidth(count) > cols {
+		line = line[1:]
+	}
+	return string(line) + a.theme.Selected.Paint(" ") + count
+}
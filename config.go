@@ -3,70 +3,175 @@ package cwl
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"gopkg.in/ini.v1"
 )
 
 const (
 	SectionNameProfile = "profile"
+	SectionNameDefault = "default"
 )
 
-func LoadAWSConfigs(ctx context.Context, excludeProfiles []string) (map[string]aws.Config, error) {
-	f := config.DefaultSharedConfigFilename()
+// EnvironmentProfile is the pseudo profile for credentials that do not come
+// from a named profile, such as environment variables or an instance role.
+const EnvironmentProfile = "(environment)"
+
+// LoadProfiles returns the names of the profiles in the shared config and
+// credentials files that are enabled by cfg. AWS_PROFILE restricts the result
+// to that profile, and EnvironmentProfile is added when credentials are set in
+// the environment or no named profile exists.
+func LoadProfiles(cfg *Config) ([]string, error) {
+	profiles := []string{}
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		profiles = append(profiles, profile)
+	} else {
+		configProfiles, err := loadSharedProfiles(sharedConfigFilename(), true)
+		if err != nil {
+			return nil, err
+		}
+		credentialsProfiles, err := loadSharedProfiles(sharedCredentialsFilename(), false)
+		if err != nil {
+			return nil, err
+		}
+		for _, profile := range append(configProfiles, credentialsProfiles...) {
+			if !slices.Contains(profiles, profile) {
+				profiles = append(profiles, profile)
+			}
+		}
+		if len(profiles) == 0 || os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+			profiles = append(profiles, EnvironmentProfile)
+		}
+	}
+
+	return slices.DeleteFunc(profiles, func(profile string) bool {
+		return !cfg.ProfileEnabled(profile)
+	}), nil
+}
+
+func sharedConfigFilename() string {
+	if f := os.Getenv("AWS_CONFIG_FILE"); f != "" {
+		return f
+	}
+	return config.DefaultSharedConfigFilename()
+}
+
+func sharedCredentialsFilename() string {
+	if f := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); f != "" {
+		return f
+	}
+	return config.DefaultSharedCredentialsFilename()
+}
+
+// loadSharedProfiles returns the profile names in a shared config file, where
+// named profiles are prefixed with "profile", or in a shared credentials file,
+// where every section is a profile. A missing file has no profiles.
+func loadSharedProfiles(f string, prefixed bool) ([]string, error) {
+	if _, err := os.Stat(f); os.IsNotExist(err) {
+		return nil, nil
+	}
 
 	inif, err := ini.Load(f)
 	if err != nil {
 		return nil, err
 	}
 
-	configs := make(map[string]aws.Config, 0)
-	mu := sync.Mutex{}
-	wg := sync.WaitGroup{}
+	profiles := []string{}
 	for _, section := range inif.Sections() {
-		if !strings.HasPrefix(section.Name(), SectionNameProfile) {
+		name := strings.TrimSpace(section.Name())
+		if name == ini.DefaultSection {
 			continue
 		}
-		profile := strings.TrimPrefix(section.Name(), SectionNameProfile)
-		profile = strings.TrimSpace(profile)
-		if slices.Contains(excludeProfiles, profile) {
+		if name == SectionNameDefault || !prefixed {
+			profiles = append(profiles, name)
 			continue
 		}
-		wg.Add(1)
-		go func(profile string) {
-			defer wg.Done()
-			defer func() {
-				if err := recover(); err != nil {
-					fmt.Println(err)
-				}
-			}()
-			cfg, err := config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(profile))
-			if err != nil {
-				return
-			}
-			_, err = cfg.Credentials.Retrieve(ctx)
-			if err != nil {
-				return
-			}
-			mu.Lock()
-			configs[profile] = cfg
-			mu.Unlock()
-		}(profile)
+		if !strings.HasPrefix(name, SectionNameProfile+" ") {
+			continue
+		}
+		profile := strings.TrimPrefix(name, SectionNameProfile)
+		profiles = append(profiles, strings.TrimSpace(profile))
 	}
-	wg.Wait()
+	return profiles, nil
+}
 
-	return configs, nil
+func awsConfigOptions(profile string) []func(*config.LoadOptions) error {
+	if profile == EnvironmentProfile {
+		return nil
+	}
+	return []func(*config.LoadOptions) error{
+		config.WithSharedConfigProfile(profile),
+	}
+}
+
+// LoadAWSConfig loads the config of profile and makes sure its credentials
+// can be retrieved.
+func LoadAWSConfig(ctx context.Context, profile string) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, awsConfigOptions(profile)...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		return aws.Config{}, err
+	}
+	return cfg, nil
 }
 
 type Config struct {
-	ExcludeProfiles []string `json:"excludeProfiles"`
+	IncludeProfiles []string                  `json:"includeProfiles"`
+	ExcludeProfiles []string                  `json:"excludeProfiles"`
+	CacheTTL        string                    `json:"cacheTTL"`
+	Regions         []string                  `json:"regions"`
+	Concurrency     int                       `json:"concurrency"`
+	Profiles        map[string]*ProfileConfig `json:"profiles"`
+	EndpointURL     string                    `json:"endpointURL"`
+
+	IncludeLinkedAccounts bool     `json:"includeLinkedAccounts"`
+	LinkedAccounts        []string `json:"linkedAccounts"`
+
+	// Wrap starts the stream view with long messages wrapped instead of
+	// truncated.
+	Wrap bool `json:"wrap"`
+
+	// Theme names one of Themes, and Highlights style the parts of log
+	// messages that match them.
+	Theme      string          `json:"theme"`
+	Highlights []HighlightRule `json:"highlights"`
+
+	// Keys rebinds the actions of the screens.
+	Keys KeysConfig `json:"keys"`
+
+	// Correlations are patterns of IDs to follow across groups, in addition
+	// to DefaultCorrelationPatterns.
+	Correlations []string `json:"correlations"`
+
+	// Multiline folds continuation lines, such as stack traces, into the
+	// event they continue.
+	Multiline MultilineConfig `json:"multiline"`
+
+	// endpointURLOverride comes from the command line and wins over every
+	// endpointURL in the file.
+	endpointURLOverride string
+	// newClient, when set, replaces the SDK client of NewClient, such as
+	// with a FakeClient in tests.
+	newClient func(profile string, cfg aws.Config) Client
+}
+
+type ProfileConfig struct {
+	Regions     []string `json:"regions"`
+	EndpointURL string   `json:"endpointURL"`
+
+	IncludeLinkedAccounts *bool    `json:"includeLinkedAccounts"`
+	LinkedAccounts        []string `json:"linkedAccounts"`
 }
 
 const (
@@ -74,6 +179,188 @@ const (
 	ConfigFile    = "cwl.json"
 )
 
+const (
+	DefaultCacheTTL    = time.Hour
+	DefaultConcurrency = 8
+)
+
+const (
+	RegionsAllEnabled = "all-enabled"
+)
+
+// EnabledRegions are the regions enabled by default in every account, used
+// for the all-enabled regions mode.
+var EnabledRegions = []string{
+	"ap-northeast-1",
+	"ap-northeast-2",
+	"ap-northeast-3",
+	"ap-south-1",
+	"ap-southeast-1",
+	"ap-southeast-2",
+	"ca-central-1",
+	"eu-central-1",
+	"eu-north-1",
+	"eu-west-1",
+	"eu-west-2",
+	"eu-west-3",
+	"sa-east-1",
+	"us-east-1",
+	"us-east-2",
+	"us-west-1",
+	"us-west-2",
+}
+
+// ProfileEnabled reports whether profile passes includeProfiles and
+// excludeProfiles. See matchProfile for the pattern syntax. An include list
+// with only negated patterns includes everything they do not negate.
+func (c *Config) ProfileEnabled(profile string) bool {
+	if len(c.IncludeProfiles) > 0 {
+		include, ok := matchProfile(c.IncludeProfiles, profile)
+		if !ok {
+			include = !slices.ContainsFunc(c.IncludeProfiles, func(pattern string) bool {
+				return !strings.HasPrefix(pattern, "!")
+			})
+		}
+		if !include {
+			return false
+		}
+	}
+	exclude, _ := matchProfile(c.ExcludeProfiles, profile)
+	return !exclude
+}
+
+// matchProfile matches profile against patterns in order and returns the
+// result of the last matching pattern, like .gitignore. A pattern is a glob
+// (sandbox-*), or a regular expression when enclosed in slashes
+// (/^prod-.*-readonly$/), and a leading "!" negates it. ok is false when no
+// pattern matches.
+func matchProfile(patterns []string, profile string) (matched bool, ok bool) {
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		var m bool
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				continue
+			}
+			m = re.MatchString(profile)
+		} else {
+			var err error
+			m, err = path.Match(pattern, profile)
+			if err != nil {
+				m = pattern == profile
+			}
+		}
+		if m {
+			matched = !negate
+			ok = true
+		}
+	}
+	return matched, ok
+}
+
+// ProfileRegions returns the regions to discover log groups in for profile.
+// Profile settings take precedence over global ones, and the region of the
+// profile itself is used when neither is set.
+func (c *Config) ProfileRegions(profile, region string) []string {
+	regions := c.Regions
+	if pc, ok := c.Profiles[profile]; ok && len(pc.Regions) > 0 {
+		regions = pc.Regions
+	}
+	if len(regions) == 0 {
+		return []string{region}
+	}
+	if slices.Contains(regions, RegionsAllEnabled) {
+		return EnabledRegions
+	}
+	return regions
+}
+
+// ProfileRegionSet returns the profile regions that log groups of profiles
+// are discovered in. The region of a profile is read from its config without
+// retrieving credentials, and a profile whose config cannot be loaded or has
+// no region to fall back to is left out.
+func (c *Config) ProfileRegionSet(ctx context.Context, profiles []string) []ProfileRegion {
+	set := []ProfileRegion{}
+	for _, profile := range profiles {
+		awscfg, err := config.LoadDefaultConfig(ctx, awsConfigOptions(profile)...)
+		if err != nil {
+			continue
+		}
+		for _, region := range c.ProfileRegions(profile, awscfg.Region) {
+			if region == "" {
+				continue
+			}
+			set = append(set, ProfileRegion{Profile: profile, Region: region})
+		}
+	}
+	return set
+}
+
+// ProfileEndpointURL returns the CloudWatch Logs endpoint to use for profile,
+// or "" for the default AWS endpoint.
+func (c *Config) ProfileEndpointURL(profile string) string {
+	if c.endpointURLOverride != "" {
+		return c.endpointURLOverride
+	}
+	if pc, ok := c.Profiles[profile]; ok && pc.EndpointURL != "" {
+		return pc.EndpointURL
+	}
+	return c.EndpointURL
+}
+
+// NewClient returns a CloudWatch Logs client for profile that talks to its
+// endpoint URL, if any.
+func (c *Config) NewClient(profile string, cfg aws.Config) Client {
+	if c.newClient != nil {
+		return c.newClient(profile, cfg)
+	}
+	endpointURL := c.ProfileEndpointURL(profile)
+	return NewClient(cloudwatchlogs.NewFromConfig(cfg, func(o *cloudwatchlogs.Options) {
+		if endpointURL != "" {
+			o.BaseEndpoint = aws.String(endpointURL)
+		}
+	}))
+}
+
+// ProfileLinkedAccounts reports whether log groups of source accounts linked
+// to profile's monitoring account are discovered, restricted to accounts when
+// not empty.
+func (c *Config) ProfileLinkedAccounts(profile string) (include bool, accounts []string) {
+	include, accounts = c.IncludeLinkedAccounts, c.LinkedAccounts
+	if pc, ok := c.Profiles[profile]; ok {
+		if pc.IncludeLinkedAccounts != nil {
+			include = *pc.IncludeLinkedAccounts
+		}
+		if len(pc.LinkedAccounts) > 0 {
+			accounts = pc.LinkedAccounts
+		}
+	}
+	return include, accounts
+}
+
+func (c *Config) DiscoveryConcurrency() int {
+	if c.Concurrency <= 0 {
+		return DefaultConcurrency
+	}
+	return c.Concurrency
+}
+
+// LogGroupCacheTTL returns how long discovered log groups are trusted before
+// a background refresh is started.
+func (c *Config) LogGroupCacheTTL() time.Duration {
+	if c.CacheTTL == "" {
+		return DefaultCacheTTL
+	}
+	ttl, err := time.ParseDuration(c.CacheTTL)
+	if err != nil {
+		return DefaultCacheTTL
+	}
+	return ttl
+}
+
 func LoadDefaultConfig(ctx context.Context) (*Config, error) {
 
 	cwd, err := os.Getwd()
@@ -116,3 +403,12 @@ func LoadConfig(ctx context.Context, path string) (*Config, error) {
 	}
 	return cfg, nil
 }
+
+// must returns v, and panics if err is set. It is for the defaults built from
+// constant input, which cannot fail unless the input is wrong.
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package cwl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultCorrelationPatterns find the IDs that tie events of different
+// groups together: X-Ray trace IDs, Lambda request IDs and the requestId and
+// traceId fields of JSON messages. The ID is the first group of a match, or
+// the whole match if the pattern has no group.
+var DefaultCorrelationPatterns = []string{
+	`\b(1-[0-9a-f]{8}-[0-9a-f]{24})\b`,
+	`RequestId: ([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`,
+	`^\S+ ([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}) (?:TRACE|DEBUG|INFO|WARN|ERROR|FATAL)\b`,
+	`"(?i:request_?id|trace_?id)"\s*:\s*"([^"]+)"`,
+}
+
+// Correlator extracts correlation IDs from log messages.
+type Correlator struct {
+	patterns []*regexp.Regexp
+}
+
+// DefaultCorrelator finds the IDs of DefaultCorrelationPatterns only.
+var DefaultCorrelator = must(NewCorrelator(nil))
+
+// NewCorrelator returns a correlator of DefaultCorrelationPatterns and
+// patterns.
+func NewCorrelator(patterns []string) (*Correlator, error) {
+	c := &Correlator{}
+	for _, pattern := range append(slices.Clone(DefaultCorrelationPatterns), patterns...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid correlation pattern %q: %w", pattern, err)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+	return c, nil
+}
+
+// IDs returns the correlation IDs in message, in the order of the patterns
+// finding them.
+func (c *Correlator) IDs(message string) []string {
+	ids := []string{}
+	for _, re := range c.patterns {
+		for _, m := range re.FindAllStringSubmatch(message, -1) {
+			id := m[0]
+			if len(m) > 1 {
+				id = m[1]
+			}
+			if id != "" && !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// eventIDs returns the correlation IDs of evt, the lines folded into it
+// included.
+func (c *Correlator) eventIDs(evt *LogEvent) []string {
+	ids := c.IDs(evt.Message())
+	for _, line := range evt.folded {
+		for _, id := range c.IDs(line.Message()) {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// mentions reports whether evt, or a line folded into it, contains id.
+func mentions(evt *LogEvent, id string) bool {
+	return strings.Contains(evt.Message(), id) || slices.ContainsFunc(evt.folded, func(line *LogEvent) bool {
+		return strings.Contains(line.Message(), id)
+	})
+}
+
+// LoadCorrelator returns the correlator of the correlations of the config.
+func (c *Config) LoadCorrelator() (*Correlator, error) {
+	return NewCorrelator(c.Correlations)
+}
@@ -0,0 +1,271 @@
+package cwl
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// FakeClient is an in-memory Client for tests and demos. Log groups and
+// events are served from its fields, and live tail sessions receive whatever
+// is passed to Emit.
+type FakeClient struct {
+	mu        sync.Mutex
+	logGroups []types.LogGroup
+	events    map[string][]types.FilteredLogEvent
+	tails     []*fakeLiveTail
+
+	// AccountID is the account of the client. Log groups of other accounts
+	// are linked and only described with IncludeLinkedAccounts.
+	AccountID string
+	// PageSize limits the log groups and events returned per call.
+	PageSize int
+	// Err, when set, is returned by every call.
+	Err error
+}
+
+func NewFakeClient(logGroups ...types.LogGroup) *FakeClient {
+	return &FakeClient{
+		logGroups: logGroups,
+		events:    make(map[string][]types.FilteredLogEvent),
+		PageSize:  50,
+	}
+}
+
+// NewFakeLogGroup returns a log group with a well-formed ARN.
+func NewFakeLogGroup(accountID, region, name string) types.LogGroup {
+	arn := fmt.Sprintf("arn:aws:logs:%s:%s:log-group:%s", region, accountID, name)
+	return types.LogGroup{
+		Arn:          aws.String(arn + ":*"),
+		LogGroupArn:  aws.String(arn),
+		LogGroupName: aws.String(name),
+	}
+}
+
+func (c *FakeClient) AddLogGroup(logGroup types.LogGroup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logGroups = append(c.logGroups, logGroup)
+}
+
+// AddEvents stores events of logGroupArn for FilterLogEvents.
+func (c *FakeClient) AddEvents(logGroupArn string, events ...types.FilteredLogEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events[logGroupArn] = append(c.events[logGroupArn], events...)
+}
+
+func (c *FakeClient) DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return nil, c.Err
+	}
+
+	logGroups := []types.LogGroup{}
+	for _, logGroup := range c.logGroups {
+		if params.LogGroupNamePrefix != nil && !strings.HasPrefix(*logGroup.LogGroupName, *params.LogGroupNamePrefix) {
+			continue
+		}
+		if !aws.ToBool(params.IncludeLinkedAccounts) && c.AccountID != "" && fakeAccountID(logGroup) != c.AccountID {
+			continue
+		}
+		if len(params.AccountIdentifiers) > 0 && !slices.Contains(params.AccountIdentifiers, fakeAccountID(logGroup)) {
+			continue
+		}
+		logGroups = append(logGroups, logGroup)
+	}
+
+	page, next, err := fakePage(logGroups, params.NextToken, c.pageSize(params.Limit))
+	if err != nil {
+		return nil, err
+	}
+	return &cloudwatchlogs.DescribeLogGroupsOutput{
+		LogGroups: page,
+		NextToken: next,
+	}, nil
+}
+
+func (c *FakeClient) FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return nil, c.Err
+	}
+
+	identifier := aws.ToString(params.LogGroupIdentifier)
+	if identifier == "" {
+		identifier = aws.ToString(params.LogGroupName)
+	}
+
+	events := []types.FilteredLogEvent{}
+	for arn, evts := range c.events {
+		if arn != identifier && !strings.HasSuffix(arn, ":log-group:"+identifier) {
+			continue
+		}
+		for _, evt := range evts {
+			if params.StartTime != nil && aws.ToInt64(evt.Timestamp) < *params.StartTime {
+				continue
+			}
+			if params.EndTime != nil && aws.ToInt64(evt.Timestamp) > *params.EndTime {
+				continue
+			}
+			if params.FilterPattern != nil && !strings.Contains(aws.ToString(evt.Message), strings.Trim(*params.FilterPattern, `"`)) {
+				continue
+			}
+			events = append(events, evt)
+		}
+	}
+	slices.SortStableFunc(events, func(a, b types.FilteredLogEvent) int {
+		return cmp.Compare(aws.ToInt64(a.Timestamp), aws.ToInt64(b.Timestamp))
+	})
+
+	page, next, err := fakePage(events, params.NextToken, c.pageSize(params.Limit))
+	if err != nil {
+		return nil, err
+	}
+	return &cloudwatchlogs.FilterLogEventsOutput{
+		Events:    page,
+		NextToken: next,
+	}, nil
+}
+
+func (c *FakeClient) LiveTail(ctx context.Context, params *cloudwatchlogs.StartLiveTailInput) (*cloudwatchlogs.StartLiveTailEventStream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return nil, c.Err
+	}
+
+	tail := &fakeLiveTail{
+		identifiers: params.LogGroupIdentifiers,
+		pattern:     strings.Trim(aws.ToString(params.LogEventFilterPattern), `"`),
+		events:      make(chan types.StartLiveTailResponseStream, 100),
+		done:        make(chan struct{}),
+	}
+	c.tails = append(c.tails, tail)
+	return cloudwatchlogs.NewStartLiveTailEventStream(func(es *cloudwatchlogs.StartLiveTailEventStream) {
+		es.Reader = tail
+	}), nil
+}
+
+// Emit sends events as one session update to every open live tail session
+// of logGroupArn. It blocks while a session's buffer is full.
+func (c *FakeClient) Emit(logGroupArn string, events ...types.LiveTailSessionLogEvent) {
+	c.mu.Lock()
+	tails := slices.Clone(c.tails)
+	c.mu.Unlock()
+
+	for i := range events {
+		if events[i].LogGroupIdentifier == nil {
+			events[i].LogGroupIdentifier = aws.String(logGroupArn)
+		}
+	}
+	for _, tail := range tails {
+		if !slices.Contains(tail.identifiers, logGroupArn) {
+			continue
+		}
+		matched := slices.DeleteFunc(slices.Clone(events), func(evt types.LiveTailSessionLogEvent) bool {
+			return !strings.Contains(aws.ToString(evt.Message), tail.pattern)
+		})
+		if len(matched) == 0 {
+			continue
+		}
+		tail.send(&types.StartLiveTailResponseStreamMemberSessionUpdate{
+			Value: types.LiveTailSessionUpdate{
+				SessionResults: matched,
+			},
+		})
+	}
+}
+
+// LiveTails returns the number of live tail sessions that are still open.
+func (c *FakeClient) LiveTails() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, tail := range c.tails {
+		if !tail.isClosed() {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *FakeClient) pageSize(limit *int32) int {
+	if limit != nil && int(*limit) < c.PageSize {
+		return int(*limit)
+	}
+	return c.PageSize
+}
+
+func fakeAccountID(logGroup types.LogGroup) string {
+	if parts := strings.Split(aws.ToString(logGroup.LogGroupArn), ":"); len(parts) > 4 {
+		return parts[4]
+	}
+	return ""
+}
+
+// fakePage returns the page of items starting at the offset in token.
+func fakePage[T any](items []T, token *string, size int) ([]T, *string, error) {
+	start := 0
+	if token != nil {
+		var err error
+		start, err = strconv.Atoi(*token)
+		if err != nil || start > len(items) {
+			return nil, nil, fmt.Errorf("invalid next token: %s", *token)
+		}
+	}
+	end := start + size
+	if size <= 0 || end >= len(items) {
+		return items[start:], nil, nil
+	}
+	return items[start:end], aws.String(strconv.Itoa(end)), nil
+}
+
+type fakeLiveTail struct {
+	identifiers []string
+	pattern     string
+	events      chan types.StartLiveTailResponseStream
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+func (t *fakeLiveTail) send(evt types.StartLiveTailResponseStream) {
+	select {
+	case t.events <- evt:
+	case <-t.done:
+	}
+}
+
+func (t *fakeLiveTail) isClosed() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *fakeLiveTail) Events() <-chan types.StartLiveTailResponseStream {
+	return t.events
+}
+
+func (t *fakeLiveTail) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+	return nil
+}
+
+func (t *fakeLiveTail) Err() error {
+	return nil
+}
@@ -0,0 +1,186 @@
+package cwl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Levels of log events, from the bottom of a histogram bar up.
+const (
+	levelError = iota
+	levelWarn
+	levelInfo
+	levelDebug
+	levelOther
+	levelCount
+)
+
+var levelPattern = regexp.MustCompile(`\b(FATAL|CRITICAL|ERROR|WARN|WARNING|INFO|DEBUG|TRACE)\b`)
+
+// detectLevel returns the level of the first level keyword in message.
+func detectLevel(message string) int {
+	switch levelPattern.FindString(message) {
+	case "FATAL", "CRITICAL", "ERROR":
+		return levelError
+	case "WARN", "WARNING":
+		return levelWarn
+	case "INFO":
+		return levelInfo
+	case "DEBUG", "TRACE":
+		return levelDebug
+	}
+	return levelOther
+}
+
+// bucketWidths are the widths histograms choose from, narrowest first.
+var bucketWidths = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 6 * time.Hour, 24 * time.Hour,
+}
+
+// histogram counts events per level in buckets of equal width.
+type histogram struct {
+	start   time.Time
+	width   time.Duration
+	buckets [][levelCount]int
+}
+
+// newHistogram spreads events over n buckets, the last of which holds end.
+// It uses the narrowest width of bucketWidths that reaches back to first.
+func newHistogram(events []*LogEvent, first, end time.Time, n int) histogram {
+	n = max(n, 1)
+	width := bucketWidths[len(bucketWidths)-1]
+	for _, w := range bucketWidths {
+		if end.Sub(first) < w*time.Duration(n-1) {
+			width = w
+			break
+		}
+	}
+	h := histogram{
+		start:   end.Truncate(width).Add(-width * time.Duration(n-1)),
+		width:   width,
+		buckets: make([][levelCount]int, n),
+	}
+	for _, evt := range events {
+		if i := h.bucket(evt.timestamp); 0 <= i && i < n {
+			h.buckets[i][evt.level]++
+		}
+	}
+	return h
+}
+
+// bucket returns the index of the bucket holding t, which is out of range
+// if no bucket does.
+func (h histogram) bucket(t time.Time) int {
+	if t.Before(h.start) {
+		return -1
+	}
+	return int(t.Sub(h.start) / h.width)
+}
+
+// window returns the times bucket i starts and ends at.
+func (h histogram) window(i int) (time.Time, time.Time) {
+	start := h.start.Add(h.width * time.Duration(i))
+	return start, start.Add(h.width)
+}
+
+func (h histogram) total(i int) int {
+	total := 0
+	for _, n := range h.buckets[i] {
+		total += n
+	}
+	return total
+}
+
+func (h histogram) max() int {
+	m := 0
+	for i := range h.buckets {
+		m = max(m, h.total(i))
+	}
+	return m
+}
+
+// scale describes the height of the fullest bucket, as "42/10s".
+func (h histogram) scale() string {
+	width := fmt.Sprintf("%ds", h.width/time.Second)
+	switch {
+	case h.width%time.Hour == 0:
+		width = fmt.Sprintf("%dh", h.width/time.Hour)
+	case h.width%time.Minute == 0:
+		width = fmt.Sprintf("%dm", h.width/time.Minute)
+	}
+	return fmt.Sprintf("%d/%s", h.max(), width)
+}
+
+var bars = []rune(" ▁▂▃▄▅▆▇█")
+
+func levelStyle(theme *Theme, level int) Style {
+	switch level {
+	case levelError:
+		return theme.Error
+	case levelWarn:
+		return theme.Warn
+	case levelInfo:
+		return theme.Info
+	case levelDebug:
+		return theme.Debug
+	}
+	return theme.Message
+}
+
+// chart draws the buckets as bars rows high, one column each, stacked by
+// level. Each cell takes the color of the level filling most of it.
+func (h histogram) chart(rows int, theme *Theme) []string {
+	top := h.max()
+	lines := make([]strings.Builder, rows)
+	for i, counts := range h.buckets {
+		total := h.total(i)
+		height := 0
+		if total > 0 {
+			height = max((total*rows*8+top-1)/top, 1)
+		}
+		for row := 0; row < rows; row++ {
+			fill := min(max(height-row*8, 0), 8)
+			if fill == 0 {
+				lines[rows-1-row].WriteRune(' ')
+				continue
+			}
+			// The level at the middle of the filled part of the cell.
+			mid := (row*8 + fill/2) * total / height
+			level, below := 0, 0
+			for level < levelOther && below+counts[level] <= mid {
+				below += counts[level]
+				level++
+			}
+			lines[rows-1-row].WriteString(levelStyle(theme, level).Paint(string(bars[fill])))
+		}
+	}
+	chart := make([]string, rows)
+	for i := range lines {
+		chart[i] = lines[i].String()
+	}
+	return chart
+}
+
+// sparkline draws the buckets as one line of bars, each in the color of the
+// most severe level in it.
+func (h histogram) sparkline(theme *Theme) string {
+	top := h.max()
+	b := strings.Builder{}
+	for i, counts := range h.buckets {
+		total := h.total(i)
+		if total == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		level := 0
+		for counts[level] == 0 {
+			level++
+		}
+		b.WriteString(levelStyle(theme, level).Paint(string(bars[max((total*8+top-1)/top, 1)])))
+	}
+	return b.String()
+}
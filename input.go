@@ -0,0 +1,454 @@
+package cwl
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is an input event decoded from the terminal: a KeyEvent or a
+// MouseEvent.
+type Event interface {
+	event()
+}
+
+type Key int
+
+const (
+	KeyRune Key = iota
+	KeyEnter
+	KeyTab
+	KeyBacktab
+	KeyBackspace
+	KeyEscape
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyPgUp
+	KeyPgDn
+	KeyInsert
+	KeyDelete
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+	KeyPaste
+)
+
+var keyNames = map[Key]string{
+	KeyEnter:     "enter",
+	KeyTab:       "tab",
+	KeyBacktab:   "backtab",
+	KeyBackspace: "backspace",
+	KeyEscape:    "esc",
+	KeyUp:        "up",
+	KeyDown:      "down",
+	KeyRight:     "right",
+	KeyLeft:      "left",
+	KeyHome:      "home",
+	KeyEnd:       "end",
+	KeyPgUp:      "pgup",
+	KeyPgDn:      "pgdn",
+	KeyInsert:    "insert",
+	KeyDelete:    "delete",
+	KeyF1:        "f1",
+	KeyF2:        "f2",
+	KeyF3:        "f3",
+	KeyF4:        "f4",
+	KeyF5:        "f5",
+	KeyF6:        "f6",
+	KeyF7:        "f7",
+	KeyF8:        "f8",
+	KeyF9:        "f9",
+	KeyF10:       "f10",
+	KeyF11:       "f11",
+	KeyF12:       "f12",
+	KeyPaste:     "paste",
+}
+
+type Mod int
+
+const (
+	ModShift Mod = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+// KeyEvent is a key press. Rune is set for KeyRune, and Text holds the
+// pasted text for KeyPaste. Control characters are decoded as KeyRune with
+// ModCtrl and the lower-case letter, so Ctrl+A is {KeyRune, 'a', ModCtrl}.
+type KeyEvent struct {
+	Key  Key
+	Rune rune
+	Mod  Mod
+	Text string
+}
+
+func (KeyEvent) event() {}
+
+// String returns the key in the notation used for key bindings, such as
+// "j", "ctrl+a", "alt+enter" or "pgdn".
+func (e KeyEvent) String() string {
+	s := ""
+	if e.Mod&ModCtrl != 0 {
+		s += "ctrl+"
+	}
+	if e.Mod&ModAlt != 0 {
+		s += "alt+"
+	}
+	if e.Mod&ModShift != 0 {
+		s += "shift+"
+	}
+	if e.Key == KeyRune {
+		if e.Rune == ' ' {
+			return s + "space"
+		}
+		return s + string(e.Rune)
+	}
+	return s + keyNames[e.Key]
+}
+
+// IsRune reports whether e is r typed without modifiers.
+func (e KeyEvent) IsRune(r rune) bool {
+	return e.Key == KeyRune && e.Mod == 0 && e.Rune == r
+}
+
+type MouseButton int
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+	MouseNone
+	MouseWheelUp
+	MouseWheelDown
+)
+
+// MouseEvent is a mouse report. X and Y are one-based cells. Release is set
+// when a button is released, and Motion while the mouse moves with a button
+// held.
+type MouseEvent struct {
+	Button  MouseButton
+	X, Y    int
+	Mod     Mod
+	Release bool
+	Motion  bool
+}
+
+func (MouseEvent) event() {}
+
+const (
+	// EscapeTimeout is how long a lone ESC waits for the rest of an escape
+	// sequence before it is decoded as the Escape key.
+	EscapeTimeout = 50 * time.Millisecond
+)
+
+// InputDecoder turns the runes read from a terminal into typed events.
+type InputDecoder struct {
+	runes   chan rune
+	err     error
+	pending []rune
+}
+
+// NewInputDecoder starts reading t in the background.
+func NewInputDecoder(t Terminal) *InputDecoder {
+	d := &InputDecoder{
+		runes: make(chan rune, 256),
+	}
+	go func() {
+		defer close(d.runes)
+		for {
+			r, err := t.Rune()
+			if err != nil {
+				d.err = err
+				return
+			}
+			d.runes <- r
+		}
+	}()
+	return d
+}
+
+// read returns the next rune, waiting at most timeout when it is positive.
+// ok is false on timeout.
+func (d *InputDecoder) read(ctx context.Context, timeout time.Duration) (r rune, ok bool, err error) {
+	if len(d.pending) > 0 {
+		r, d.pending = d.pending[0], d.pending[1:]
+		return r, true, nil
+	}
+
+	var after <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		after = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return 0, false, ctx.Err()
+	case <-after:
+		return 0, false, nil
+	case r, ok := <-d.runes:
+		if !ok {
+			return 0, false, d.err
+		}
+		return r, true, nil
+	}
+}
+
+// Next blocks until the next event is decoded.
+func (d *InputDecoder) Next(ctx context.Context) (Event, error) {
+	r, _, err := d.read(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if r != 0x1b {
+		return decodeRune(r), nil
+	}
+
+	r, ok, err := d.read(ctx, EscapeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return KeyEvent{Key: KeyEscape}, nil
+	}
+	switch r {
+	case '[':
+		return d.csi(ctx)
+	case 'O':
+		return d.ss3(ctx)
+	case 0x1b:
+		d.pending = append(d.pending, r)
+		return KeyEvent{Key: KeyEscape}, nil
+	}
+	key := decodeRune(r)
+	key.Mod |= ModAlt
+	return key, nil
+}
+
+func decodeRune(r rune) KeyEvent {
+	switch {
+	case r == '\r' || r == '\n':
+		return KeyEvent{Key: KeyEnter}
+	case r == '\t':
+		return KeyEvent{Key: KeyTab}
+	case r == 127 || r == 8:
+		return KeyEvent{Key: KeyBackspace}
+	case r == 0:
+		return KeyEvent{Key: KeyRune, Rune: ' ', Mod: ModCtrl}
+	case 1 <= r && r <= 26:
+		return KeyEvent{Key: KeyRune, Rune: 'a' + r - 1, Mod: ModCtrl}
+	}
+	return KeyEvent{Key: KeyRune, Rune: r}
+}
+
+// csi decodes the control sequence after ESC [.
+func (d *InputDecoder) csi(ctx context.Context) (Event, error) {
+	params := []rune{}
+	for {
+		r, ok, err := d.read(ctx, EscapeTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// The sequence was cut short, so the runes read so far are keys
+			// typed after Alt+[.
+			d.pending = append(params, d.pending...)
+			return KeyEvent{Key: KeyRune, Rune: '[', Mod: ModAlt}, nil
+		}
+		if len(params) == 0 && r == 'M' {
+			return d.x10Mouse(ctx)
+		}
+		if r >= 0x40 && r <= 0x7e {
+			return d.csiFinal(ctx, string(params), r)
+		}
+		params = append(params, r)
+	}
+}
+
+func (d *InputDecoder) csiFinal(ctx context.Context, params string, final rune) (Event, error) {
+	if strings.HasPrefix(params, "<") {
+		return sgrMouse(params[1:], final), nil
+	}
+
+	args := strings.Split(params, ";")
+	mod := Mod(0)
+	if len(args) > 1 {
+		mod = xtermMod(args[1])
+	}
+
+	switch final {
+	case 'A':
+		return KeyEvent{Key: KeyUp, Mod: mod}, nil
+	case 'B':
+		return KeyEvent{Key: KeyDown, Mod: mod}, nil
+	case 'C':
+		return KeyEvent{Key: KeyRight, Mod: mod}, nil
+	case 'D':
+		return KeyEvent{Key: KeyLeft, Mod: mod}, nil
+	case 'H':
+		return KeyEvent{Key: KeyHome, Mod: mod}, nil
+	case 'F':
+		return KeyEvent{Key: KeyEnd, Mod: mod}, nil
+	case 'P', 'Q', 'R', 'S':
+		return KeyEvent{Key: KeyF1 + Key(final-'P'), Mod: mod}, nil
+	case 'Z':
+		return KeyEvent{Key: KeyBacktab}, nil
+	case '~':
+		n, _ := strconv.Atoi(args[0])
+		if n == 200 {
+			return d.paste(ctx)
+		}
+		if key, ok := tildeKeys[n]; ok {
+			return KeyEvent{Key: key, Mod: mod}, nil
+		}
+	}
+	// Unknown sequences are dropped rather than leaking into the screen as
+	// runes.
+	return d.Next(ctx)
+}
+
+var tildeKeys = map[int]Key{
+	1:  KeyHome,
+	2:  KeyInsert,
+	3:  KeyDelete,
+	4:  KeyEnd,
+	5:  KeyPgUp,
+	6:  KeyPgDn,
+	7:  KeyHome,
+	8:  KeyEnd,
+	11: KeyF1,
+	12: KeyF2,
+	13: KeyF3,
+	14: KeyF4,
+	15: KeyF5,
+	17: KeyF6,
+	18: KeyF7,
+	19: KeyF8,
+	20: KeyF9,
+	21: KeyF10,
+	23: KeyF11,
+	24: KeyF12,
+}
+
+// xtermMod decodes the modifier parameter of xterm, which is one plus a
+// bitmask of shift, alt and ctrl.
+func xtermMod(param string) Mod {
+	n, err := strconv.Atoi(param)
+	if err != nil || n < 1 {
+		return 0
+	}
+	return Mod(n - 1)
+}
+
+// ss3 decodes the sequence after ESC O sent for F1-F4 and, in application
+// cursor mode, arrows.
+func (d *InputDecoder) ss3(ctx context.Context) (Event, error) {
+	r, ok, err := d.read(ctx, EscapeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return KeyEvent{Key: KeyRune, Rune: 'O', Mod: ModAlt}, nil
+	}
+	switch r {
+	case 'A':
+		return KeyEvent{Key: KeyUp}, nil
+	case 'B':
+		return KeyEvent{Key: KeyDown}, nil
+	case 'C':
+		return KeyEvent{Key: KeyRight}, nil
+	case 'D':
+		return KeyEvent{Key: KeyLeft}, nil
+	case 'H':
+		return KeyEvent{Key: KeyHome}, nil
+	case 'F':
+		return KeyEvent{Key: KeyEnd}, nil
+	case 'P', 'Q', 'R', 'S':
+		return KeyEvent{Key: KeyF1 + Key(r-'P')}, nil
+	}
+	return d.Next(ctx)
+}
+
+// paste collects bracketed paste text up to ESC [ 201 ~.
+func (d *InputDecoder) paste(ctx context.Context) (Event, error) {
+	const end = "\x1b[201~"
+	text := []rune{}
+	for {
+		r, _, err := d.read(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		text = append(text, r)
+		if strings.HasSuffix(string(text), end) {
+			return KeyEvent{Key: KeyPaste, Text: strings.TrimSuffix(string(text), end)}, nil
+		}
+	}
+}
+
+// x10Mouse decodes a legacy mouse report, whose three bytes are offset by 32.
+func (d *InputDecoder) x10Mouse(ctx context.Context) (Event, error) {
+	buf := [3]rune{}
+	for i := range buf {
+		r, _, err := d.read(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		buf[i] = r
+	}
+	evt := mouseEvent(int(buf[0]) - 32)
+	evt.X = int(buf[1]) - 32
+	evt.Y = int(buf[2]) - 32
+	if evt.Button == MouseNone {
+		evt.Release = true
+	}
+	return evt, nil
+}
+
+// sgrMouse decodes an SGR mouse report "b;x;y" ending with M on press and m
+// on release.
+func sgrMouse(params string, final rune) MouseEvent {
+	args := strings.Split(params, ";")
+	for len(args) < 3 {
+		args = append(args, "0")
+	}
+	b, _ := strconv.Atoi(args[0])
+	evt := mouseEvent(b)
+	evt.X, _ = strconv.Atoi(args[1])
+	evt.Y, _ = strconv.Atoi(args[2])
+	evt.Release = final == 'm'
+	return evt
+}
+
+func mouseEvent(b int) MouseEvent {
+	evt := MouseEvent{}
+	if b&4 != 0 {
+		evt.Mod |= ModShift
+	}
+	if b&8 != 0 {
+		evt.Mod |= ModAlt
+	}
+	if b&16 != 0 {
+		evt.Mod |= ModCtrl
+	}
+	evt.Motion = b&32 != 0
+	if b&64 != 0 {
+		evt.Button = MouseWheelUp + MouseButton(b&1)
+	} else {
+		evt.Button = MouseButton(b & 3)
+	}
+	return evt
+}
@@ -0,0 +1,42 @@
+package cwl
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInputDecoderSplitCSI(t *testing.T) {
+	vt := NewVirtualTerminal(24, 80)
+	d := NewInputDecoder(vt)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The rest of ESC [ 1 ; 5 A arrives after the escape timeout.
+	vt.Type("\x1b[1;5")
+	want := []KeyEvent{
+		{Key: KeyRune, Rune: '[', Mod: ModAlt},
+		{Key: KeyRune, Rune: '1'},
+		{Key: KeyRune, Rune: ';'},
+		{Key: KeyRune, Rune: '5'},
+	}
+	for _, w := range want {
+		ev, err := d.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev != w {
+			t.Errorf("got %#v, want %#v", ev, w)
+		}
+	}
+
+	time.Sleep(2 * EscapeTimeout)
+	vt.Type("A")
+	ev, err := d.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := (KeyEvent{Key: KeyRune, Rune: 'A'}); ev != w {
+		t.Errorf("got %#v, want %#v", ev, w)
+	}
+}
@@ -0,0 +1,408 @@
+package cwl
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Action is what a key binding does. Actions shared by the screens have
+// plain names, and the others are prefixed with the screen they belong to.
+type Action string
+
+const (
+	ActionUp      Action = "up"
+	ActionDown    Action = "down"
+	ActionTop     Action = "top"
+	ActionBottom  Action = "bottom"
+	ActionSelect  Action = "select"
+	ActionApply   Action = "apply"
+	ActionBack    Action = "back"
+	ActionCancel  Action = "cancel"
+	ActionHelp    Action = "help"
+	ActionCommand Action = "command"
+
+	ActionLoadingLogin Action = "loading.login"
+
+	ActionPickerToggleAll Action = "picker.toggle-all"
+
+	ActionChoosePageUp      Action = "choose.page-up"
+	ActionChoosePageDown    Action = "choose.page-down"
+	ActionChooseSearch      Action = "choose.search"
+	ActionChooseResetFilter Action = "choose.reset-filter"
+	ActionChooseRefresh     Action = "choose.refresh"
+	ActionChooseProfiles    Action = "choose.profiles"
+	ActionChooseLogin       Action = "choose.login"
+
+	ActionDisplayPageUp     Action = "display.page-up"
+	ActionDisplayPageDown   Action = "display.page-down"
+	ActionDisplayNextGroup  Action = "display.next-group"
+	ActionDisplayPrevGroup  Action = "display.prev-group"
+	ActionDisplayToggleLive Action = "display.toggle-live"
+	ActionDisplayDetail     Action = "display.detail"
+	ActionDisplayWrap       Action = "display.wrap"
+	ActionDisplaySplit      Action = "display.split"
+	ActionDisplayFocusNext  Action = "display.focus-next"
+	ActionDisplayFocusPrev  Action = "display.focus-prev"
+	ActionDisplayGrow       Action = "display.grow"
+	ActionDisplayShrink     Action = "display.shrink"
+	ActionDisplayZoom       Action = "display.zoom"
+	ActionDisplayHistogram  Action = "display.histogram"
+	ActionDisplayPrevBucket Action = "display.prev-bucket"
+	ActionDisplayNextBucket Action = "display.next-bucket"
+
+	ActionDisplayBookmark     Action = "display.bookmark"
+	ActionDisplayBookmarks    Action = "display.bookmarks"
+	ActionDisplayNextBookmark Action = "display.next-bookmark"
+	ActionDisplayPrevBookmark Action = "display.prev-bookmark"
+	ActionDisplayFollow       Action = "display.follow"
+	ActionDisplayInvocations  Action = "display.invocations"
+
+	ActionTimelinePageUp   Action = "timeline.page-up"
+	ActionTimelinePageDown Action = "timeline.page-down"
+	ActionTimelineBackfill Action = "timeline.backfill"
+)
+
+// DefaultBindings are the keys of every action. Keys are written as
+// KeyEvent.String formats them, and keys of a sequence are separated by
+// spaces, as in "g g".
+var DefaultBindings = map[Action][]string{
+	ActionUp:     {"k", "up"},
+	ActionDown:   {"j", "down"},
+	ActionTop:    {"g g", "home"},
+	ActionBottom: {"G", "end"},
+	ActionSelect: {"space"},
+	ActionApply:  {"enter"},
+	ActionBack:   {"backspace"},
+	ActionCancel: {"esc"},
+	ActionHelp:   {"?"},
+
+	ActionCommand: {":"},
+
+	ActionLoadingLogin: {"l"},
+
+	ActionPickerToggleAll: {"a"},
+
+	ActionChoosePageUp:      {"h", "pgup"},
+	ActionChoosePageDown:    {"l", "pgdn"},
+	ActionChooseSearch:      {"/"},
+	ActionChooseResetFilter: {"r"},
+	ActionChooseRefresh:     {"R"},
+	ActionChooseProfiles:    {"p"},
+	ActionChooseLogin:       {"L"},
+
+	ActionDisplayPageUp:     {"K", "pgup"},
+	ActionDisplayPageDown:   {"J", "pgdn"},
+	ActionDisplayNextGroup:  {"l", "right"},
+	ActionDisplayPrevGroup:  {"h", "left"},
+	ActionDisplayToggleLive: {","},
+	ActionDisplayDetail:     {"space"},
+	ActionDisplayWrap:       {"w"},
+	ActionDisplaySplit:      {"s"},
+	ActionDisplayFocusNext:  {"tab"},
+	ActionDisplayFocusPrev:  {"backtab"},
+	ActionDisplayGrow:       {"+", "="},
+	ActionDisplayShrink:     {"-"},
+	ActionDisplayZoom:       {"z"},
+	ActionDisplayHistogram:  {"H"},
+	ActionDisplayPrevBucket: {"["},
+	ActionDisplayNextBucket: {"]"},
+
+	ActionDisplayBookmark:     {"m"},
+	ActionDisplayBookmarks:    {"M"},
+	ActionDisplayNextBookmark: {"n"},
+	ActionDisplayPrevBookmark: {"N"},
+	ActionDisplayFollow:       {"t"},
+	ActionDisplayInvocations:  {"i"},
+
+	ActionTimelinePageUp:   {"K", "pgup"},
+	ActionTimelinePageDown: {"J", "pgdn"},
+	ActionTimelineBackfill: {"b"},
+}
+
+// actionLabels describe the actions in the help overlay.
+var actionLabels = map[Action]string{
+	ActionUp:     "move up",
+	ActionDown:   "move down",
+	ActionTop:    "go to the top",
+	ActionBottom: "go to the bottom",
+	ActionSelect: "select/unselect",
+	ActionApply:  "apply",
+	ActionBack:   "go back",
+	ActionCancel: "cancel",
+	ActionHelp:   "show this help",
+
+	ActionCommand: "open the command line",
+
+	ActionLoadingLogin: "SSO login",
+
+	ActionPickerToggleAll: "enable/disable all profiles",
+
+	ActionChoosePageUp:      "previous page",
+	ActionChoosePageDown:    "next page",
+	ActionChooseSearch:      "search log groups",
+	ActionChooseResetFilter: "reset the search",
+	ActionChooseRefresh:     "refresh log groups",
+	ActionChooseProfiles:    "pick profiles",
+	ActionChooseLogin:       "SSO login",
+
+	ActionDisplayPageUp:     "page up",
+	ActionDisplayPageDown:   "page down",
+	ActionDisplayNextGroup:  "next log group",
+	ActionDisplayPrevGroup:  "previous log group",
+	ActionDisplayToggleLive: "pause/resume live tail",
+	ActionDisplayDetail:     "show/hide event details",
+	ActionDisplayWrap:       "wrap/truncate long messages",
+	ActionDisplaySplit:      "tile groups side by side, stacked or not",
+	ActionDisplayFocusNext:  "focus the next pane",
+	ActionDisplayFocusPrev:  "focus the previous pane",
+	ActionDisplayGrow:       "grow the focused pane",
+	ActionDisplayShrink:     "shrink the focused pane",
+	ActionDisplayZoom:       "zoom/unzoom the focused pane",
+	ActionDisplayHistogram:  "show/hide the event histogram",
+	ActionDisplayPrevBucket: "go to the previous histogram bucket",
+	ActionDisplayNextBucket: "go to the next histogram bucket",
+
+	ActionDisplayBookmark:     "bookmark/unbookmark the event",
+	ActionDisplayBookmarks:    "show/hide the bookmarks",
+	ActionDisplayNextBookmark: "go to the next bookmark",
+	ActionDisplayPrevBookmark: "go to the previous bookmark",
+	ActionDisplayFollow:       "follow the trace or request ID of the event",
+	ActionDisplayInvocations:  "group/ungroup Lambda invocations",
+
+	ActionTimelinePageUp:   "page up",
+	ActionTimelinePageDown: "page down",
+	ActionTimelineBackfill: "search groups without the ID",
+}
+
+// KeymapPresets replace the default keys of the actions they list.
+var KeymapPresets = map[string]map[Action][]string{
+	"vim": {
+		ActionUp:                {"k", "up", "ctrl+p"},
+		ActionDown:              {"j", "down", "ctrl+n"},
+		ActionBack:              {"q", "backspace"},
+		ActionChoosePageUp:      {"ctrl+b", "ctrl+u", "pgup"},
+		ActionChoosePageDown:    {"ctrl+f", "ctrl+d", "pgdn"},
+		ActionDisplayPageUp:     {"ctrl+b", "ctrl+u", "pgup"},
+		ActionDisplayPageDown:   {"ctrl+f", "ctrl+d", "pgdn"},
+		ActionTimelinePageUp:    {"ctrl+b", "ctrl+u", "pgup"},
+		ActionTimelinePageDown:  {"ctrl+f", "ctrl+d", "pgdn"},
+		ActionDisplayNextGroup:  {"g t", "l", "right"},
+		ActionDisplayPrevGroup:  {"g T", "h", "left"},
+		ActionDisplayToggleLive: {"f", ","},
+		ActionDisplayFocusNext:  {"ctrl+w w", "tab"},
+		ActionDisplayFocusPrev:  {"ctrl+w W", "backtab"},
+		ActionDisplayGrow:       {"ctrl+w +", "+", "="},
+		ActionDisplayShrink:     {"ctrl+w -", "-"},
+		ActionDisplayZoom:       {"ctrl+w o", "z"},
+	},
+	"emacs": {
+		ActionUp:                {"ctrl+p", "up"},
+		ActionDown:              {"ctrl+n", "down"},
+		ActionTop:               {"alt+<", "home"},
+		ActionBottom:            {"alt+>", "end"},
+		ActionSelect:            {"space", "ctrl+space"},
+		ActionCancel:            {"ctrl+g", "esc"},
+		ActionChoosePageUp:      {"alt+v", "pgup"},
+		ActionChoosePageDown:    {"ctrl+v", "pgdn"},
+		ActionChooseSearch:      {"ctrl+s", "/"},
+		ActionDisplayPageUp:     {"alt+v", "pgup"},
+		ActionDisplayPageDown:   {"ctrl+v", "pgdn"},
+		ActionTimelinePageUp:    {"alt+v", "pgup"},
+		ActionTimelinePageDown:  {"ctrl+v", "pgdn"},
+		ActionDisplayNextGroup:  {"ctrl+f", "right"},
+		ActionDisplayPrevGroup:  {"ctrl+b", "left"},
+		ActionDisplayToggleLive: {"ctrl+t", ","},
+	},
+}
+
+type binding struct {
+	text string
+	keys []KeyEvent
+}
+
+// Keymap maps key sequences to actions.
+type Keymap struct {
+	bindings map[Action][]binding
+}
+
+// DefaultKeymap is the keymap of DefaultBindings.
+var DefaultKeymap = must(NewKeymap(DefaultBindings))
+
+// screenActions are the actions each screen reads keys for. A key sequence
+// can only be bound once among them, and not as a prefix of another one.
+var screenActions = map[string][]Action{
+	"loading":        loadingActions,
+	"sso login":      ssoLoginActions,
+	"profile picker": profilePickerActions,
+	"choose":         chooseLogsActions,
+	"display":        displayLogActions,
+	"timeline":       timelineActions,
+}
+
+// NewKeymap returns the keymap of bindings, which must not collide on any
+// screen.
+func NewKeymap(bindings map[Action][]string) (*Keymap, error) {
+	k := &Keymap{bindings: make(map[Action][]binding, len(bindings))}
+	for action, texts := range bindings {
+		for _, text := range texts {
+			b := binding{text: text}
+			for _, field := range strings.Fields(text) {
+				key, err := ParseKey(field)
+				if err != nil {
+					return nil, fmt.Errorf("invalid key for %s: %w", action, err)
+				}
+				b.keys = append(b.keys, key)
+			}
+			if len(b.keys) == 0 {
+				return nil, fmt.Errorf("empty key for %s", action)
+			}
+			k.bindings[action] = append(k.bindings[action], b)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(screenActions)) {
+		if err := k.check(screenActions[name]); err != nil {
+			return nil, fmt.Errorf("%s screen: %w", name, err)
+		}
+	}
+	return k, nil
+}
+
+// check returns an error if two bindings of actions are the same key
+// sequence or one is a prefix of the other, which would shadow it.
+func (k *Keymap) check(actions []Action) error {
+	for i, a := range actions {
+		for _, b := range actions[i:] {
+			for _, x := range k.bindings[a] {
+				for _, y := range k.bindings[b] {
+					if a == b && x.text == y.text {
+						continue
+					}
+					n := min(len(x.keys), len(y.keys))
+					if slices.Equal(x.keys[:n], y.keys[:n]) {
+						return fmt.Errorf("%q of %s collides with %q of %s", x.text, a, y.text, b)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Keys returns the keys bound to action as they were written.
+func (k *Keymap) Keys(action Action) []string {
+	keys := []string{}
+	for _, b := range k.bindings[action] {
+		keys = append(keys, b.text)
+	}
+	return keys
+}
+
+// ParseKey parses one key written as KeyEvent.String formats it, such as
+// "j", "G", "ctrl+d", "alt+v", "space" or "pgdn".
+func ParseKey(s string) (KeyEvent, error) {
+	key := KeyEvent{}
+	name := s
+	for {
+		switch {
+		case len(name) > len("ctrl+") && strings.HasPrefix(name, "ctrl+"):
+			key.Mod |= ModCtrl
+			name = name[len("ctrl+"):]
+			continue
+		case len(name) > len("alt+") && strings.HasPrefix(name, "alt+"):
+			key.Mod |= ModAlt
+			name = name[len("alt+"):]
+			continue
+		case len(name) > len("shift+") && strings.HasPrefix(name, "shift+"):
+			key.Mod |= ModShift
+			name = name[len("shift+"):]
+			continue
+		}
+		break
+	}
+
+	if name == "space" {
+		key.Rune = ' '
+		return key, nil
+	}
+	for k, n := range keyNames {
+		if n == name && k != KeyPaste {
+			key.Key = k
+			return key, nil
+		}
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		key.Rune, _ = utf8.DecodeRuneInString(name)
+		return key, nil
+	}
+	return KeyEvent{}, fmt.Errorf("unknown key: %s", s)
+}
+
+// KeysConfig is the keys section of the config. Preset names one of
+// KeymapPresets, and Bindings replace the keys of the actions they list.
+type KeysConfig struct {
+	Preset   string              `json:"preset"`
+	Bindings map[string][]string `json:"bindings"`
+}
+
+// LoadKeymap returns the default keymap with the preset and bindings of the
+// config applied.
+func (c *Config) LoadKeymap() (*Keymap, error) {
+	bindings := maps.Clone(DefaultBindings)
+	if c.Keys.Preset != "" {
+		preset, ok := KeymapPresets[c.Keys.Preset]
+		if !ok {
+			return nil, fmt.Errorf("unknown key preset: %s", c.Keys.Preset)
+		}
+		maps.Copy(bindings, preset)
+	}
+	for name, keys := range c.Keys.Bindings {
+		if _, ok := DefaultBindings[Action(name)]; !ok {
+			return nil, fmt.Errorf("unknown action: %s", name)
+		}
+		bindings[Action(name)] = keys
+	}
+	return NewKeymap(bindings)
+}
+
+// keySequence collects the keys typed so far of a multi-key binding.
+type keySequence struct {
+	keys []KeyEvent
+}
+
+// Read adds key to the sequence and returns the action among actions whose
+// binding it completes. While the sequence is a prefix of a binding, Read
+// returns false and waits for the next key.
+func (q *keySequence) Read(keymap *Keymap, actions []Action, key KeyEvent) (Action, bool) {
+	if key.Key == KeyPaste {
+		q.keys = nil
+		return "", false
+	}
+	q.keys = append(q.keys, key)
+
+	prefix := false
+	for _, action := range actions {
+		for _, b := range keymap.bindings[action] {
+			if slices.Equal(b.keys, q.keys) {
+				q.keys = nil
+				return action, true
+			}
+			if len(b.keys) > len(q.keys) && slices.Equal(b.keys[:len(q.keys)], q.keys) {
+				prefix = true
+			}
+		}
+	}
+	if prefix {
+		return "", false
+	}
+
+	// A key that breaks a sequence may start a binding of its own.
+	if len(q.keys) > 1 {
+		q.keys = nil
+		return q.Read(keymap, actions, key)
+	}
+	q.keys = nil
+	return "", false
+}
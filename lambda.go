@@ -0,0 +1,473 @@
+package cwl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of lines of Lambda function logs.
+const (
+	lineOther = iota
+	lineLog
+	lineStart
+	lineEnd
+	lineReport
+)
+
+var (
+	lambdaStartPattern  = regexp.MustCompile(`^START RequestId: (\S+)`)
+	lambdaEndPattern    = regexp.MustCompile(`^END RequestId: (\S+)`)
+	lambdaReportPattern = regexp.MustCompile(`^REPORT RequestId: (\S+)\s+Duration: ([\d.]+) ms\s+Billed Duration: (\d+) ms\s+Memory Size: (\d+) MB\s+Max Memory Used: (\d+) MB(?:\s+Init Duration: ([\d.]+) ms)?`)
+	lambdaStatusPattern = regexp.MustCompile(`\sStatus: (\w+)`)
+	// Text log lines of the runtimes have the request ID in one of their
+	// first fields, as in "2024-01-01T00:00:00.000Z <id> INFO message" or
+	// "[INFO] 2024-01-01T00:00:00.000Z <id> message".
+	lambdaRequestIDPattern = regexp.MustCompile(`^(?:\S+\s+){1,2}([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\s`)
+)
+
+// isLambda reports whether log holds the logs of a Lambda function.
+func isLambda(log *LogGroup) bool {
+	return strings.HasPrefix(log.Name(), "/aws/lambda/")
+}
+
+// lambdaReport is the REPORT line of an invocation.
+type lambdaReport struct {
+	duration     time.Duration
+	billed       time.Duration
+	memorySize   int
+	memoryUsed   int
+	initDuration time.Duration
+	// status is "success", "error" or "timeout", if the runtime reports it.
+	status string
+}
+
+// lambdaLine is a parsed line of Lambda function logs.
+type lambdaLine struct {
+	kind      int
+	requestID string
+	report    lambdaReport
+}
+
+// lambdaJSON is a line of the JSON log format, either of the function or of
+// the platform.
+type lambdaJSON struct {
+	Type      string `json:"type"`
+	RequestID string `json:"requestId"`
+	Record    struct {
+		RequestID string `json:"requestId"`
+		Status    string `json:"status"`
+		Metrics   struct {
+			DurationMs       float64 `json:"durationMs"`
+			BilledDurationMs float64 `json:"billedDurationMs"`
+			MemorySizeMB     int     `json:"memorySizeMB"`
+			MaxMemoryUsedMB  int     `json:"maxMemoryUsedMB"`
+			InitDurationMs   float64 `json:"initDurationMs"`
+		} `json:"metrics"`
+	} `json:"record"`
+}
+
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// parseLambda parses message, a line of the text or JSON log format.
+func parseLambda(message string) lambdaLine {
+	if strings.HasPrefix(message, "{") {
+		var j lambdaJSON
+		if err := json.Unmarshal([]byte(message), &j); err == nil {
+			return parseLambdaJSON(j)
+		}
+	}
+	if m := lambdaReportPattern.FindStringSubmatch(message); m != nil {
+		duration, _ := strconv.ParseFloat(m[2], 64)
+		billed, _ := strconv.ParseFloat(m[3], 64)
+		size, _ := strconv.Atoi(m[4])
+		used, _ := strconv.Atoi(m[5])
+		init, _ := strconv.ParseFloat(m[6], 64)
+		report := lambdaReport{
+			duration:     milliseconds(duration),
+			billed:       milliseconds(billed),
+			memorySize:   size,
+			memoryUsed:   used,
+			initDuration: milliseconds(init),
+		}
+		if s := lambdaStatusPattern.FindStringSubmatch(message); s != nil {
+			report.status = s[1]
+		}
+		return lambdaLine{kind: lineReport, requestID: m[1], report: report}
+	}
+	if m := lambdaStartPattern.FindStringSubmatch(message); m != nil {
+		return lambdaLine{kind: lineStart, requestID: m[1]}
+	}
+	if m := lambdaEndPattern.FindStringSubmatch(message); m != nil {
+		return lambdaLine{kind: lineEnd, requestID: m[1]}
+	}
+	if m := lambdaRequestIDPattern.FindStringSubmatch(message); m != nil {
+		return lambdaLine{kind: lineLog, requestID: m[1]}
+	}
+	return lambdaLine{kind: lineOther}
+}
+
+func parseLambdaJSON(j lambdaJSON) lambdaLine {
+	switch j.Type {
+	case "platform.start":
+		return lambdaLine{kind: lineStart, requestID: j.Record.RequestID}
+	case "platform.runtimeDone":
+		return lambdaLine{kind: lineEnd, requestID: j.Record.RequestID}
+	case "platform.report":
+		m := j.Record.Metrics
+		return lambdaLine{kind: lineReport, requestID: j.Record.RequestID, report: lambdaReport{
+			duration:     milliseconds(m.DurationMs),
+			billed:       milliseconds(m.BilledDurationMs),
+			memorySize:   m.MemorySizeMB,
+			memoryUsed:   m.MaxMemoryUsedMB,
+			initDuration: milliseconds(m.InitDurationMs),
+			status:       j.Record.Status,
+		}}
+	case "":
+		if j.RequestID != "" {
+			return lambdaLine{kind: lineLog, requestID: j.RequestID}
+		}
+	}
+	return lambdaLine{kind: lineOther}
+}
+
+// invocation is the events of one request to a function.
+type invocation struct {
+	requestID string
+	events    []*LogEvent
+	report    lambdaReport
+	reported  bool
+	failed    bool
+}
+
+func (inv *invocation) cold() bool {
+	return inv.report.initDuration > 0
+}
+
+// lambdaIndex groups the buffered events of a Lambda group by request ID as
+// they are buffered, so that they are not parsed and grouped again on every
+// frame. Its invocations are in the order the requests started.
+type lambdaIndex struct {
+	invs    []*invocation
+	byID    map[string]*invocation
+	owner   map[*LogEvent]*invocation
+	running *invocation
+	summary lambdaSummary
+}
+
+func newLambdaIndex(events []*LogEvent) *lambdaIndex {
+	x := &lambdaIndex{
+		byID:  make(map[string]*invocation),
+		owner: make(map[*LogEvent]*invocation),
+	}
+	for _, evt := range events {
+		x.add(evt)
+	}
+	x.summary = summarize(x.invs)
+	return x
+}
+
+// add groups evt, the newest event of the buffer. Lines without a request ID
+// belong to the request running when they were written, and are left out
+// outside of requests.
+func (x *lambdaIndex) add(evt *LogEvent) {
+	line := evt.lambdaLine()
+	inv := x.running
+	if line.requestID != "" {
+		inv = x.byID[line.requestID]
+		if inv == nil {
+			inv = &invocation{requestID: line.requestID}
+			x.byID[line.requestID] = inv
+			x.invs = append(x.invs, inv)
+		}
+	}
+	if inv == nil {
+		return
+	}
+	inv.events = append(inv.events, evt)
+	x.owner[evt] = inv
+	switch line.kind {
+	case lineStart:
+		x.running = inv
+	case lineEnd:
+		x.running = nil
+	case lineReport:
+		x.running = nil
+		inv.report, inv.reported = line.report, true
+		if line.report.status != "" && line.report.status != "success" {
+			inv.failed = true
+		}
+	}
+	if evt.level == levelError || strings.Contains(evt.Message(), "Task timed out") {
+		inv.failed = true
+	}
+}
+
+// drop removes evt, an event trimmed from the buffer, and its invocation once
+// none of its events are left.
+func (x *lambdaIndex) drop(evt *LogEvent) {
+	inv := x.owner[evt]
+	if inv == nil {
+		return
+	}
+	delete(x.owner, evt)
+	inv.events = slices.DeleteFunc(inv.events, func(e *LogEvent) bool {
+		return e == evt
+	})
+	if len(inv.events) > 0 {
+		return
+	}
+	x.invs = slices.DeleteFunc(x.invs, func(i *invocation) bool {
+		return i == inv
+	})
+	delete(x.byID, inv.requestID)
+	if x.running == inv {
+		x.running = nil
+	}
+}
+
+// lambdaLine returns the event parsed as a line of Lambda function logs. The
+// event is parsed once, with the buffer it is added to locked.
+func (e *LogEvent) lambdaLine() lambdaLine {
+	if e.lambda == nil {
+		line := parseLambda(e.Message())
+		e.lambda = &line
+	}
+	return *e.lambda
+}
+
+// indexInvocations groups the buffer of arn, if it is of a Lambda group, after
+// the buffer was replaced.
+func (s *DisplayLogScreen) indexInvocations(arn string) {
+	if _, ok := s.lambdas[arn]; ok {
+		s.lambdas[arn] = newLambdaIndex(s.buffers[arn])
+	}
+}
+
+// lambdaSummary describes the reported invocations of a function.
+type lambdaSummary struct {
+	count    int
+	errors   int
+	cold     int
+	p50, p99 time.Duration
+}
+
+func summarize(invs []*invocation) lambdaSummary {
+	sum := lambdaSummary{}
+	durations := []time.Duration{}
+	for _, inv := range invs {
+		if !inv.reported {
+			continue
+		}
+		sum.count++
+		if inv.failed {
+			sum.errors++
+		}
+		if inv.cold() {
+			sum.cold++
+		}
+		durations = append(durations, inv.report.duration)
+	}
+	slices.Sort(durations)
+	sum.p50, sum.p99 = percentile(durations, 0.5), percentile(durations, 0.99)
+	return sum
+}
+
+// percentile returns the nearest-rank percentile p of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[max(int(math.Ceil(p*float64(len(sorted))))-1, 0)]
+}
+
+func (sum lambdaSummary) String() string {
+	if sum.count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d inv p50 %s p99 %s %d err %d%% cold",
+		sum.count, formatDuration(sum.p50), formatDuration(sum.p99), sum.errors, sum.cold*100/sum.count)
+}
+
+// formatDuration formats d in milliseconds, or seconds from 10s on.
+func formatDuration(d time.Duration) string {
+	if d >= 10*time.Second {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return fmt.Sprintf("%.0fms", float64(d)/float64(time.Millisecond))
+}
+
+// invocationRow is a row of the invocations view: an invocation, or one of
+// its events when it is expanded.
+type invocationRow struct {
+	inv   *invocation
+	event *LogEvent
+}
+
+// invocations returns the invocations of arn, or nil if it is not a Lambda
+// group.
+func (s *DisplayLogScreen) invocations(arn string) []*invocation {
+	if x := s.lambdas[arn]; x != nil {
+		return x.invs
+	}
+	return nil
+}
+
+// invocationCount returns the number of rows of the invocations view of arn.
+func (s *DisplayLogScreen) invocationCount(arn string) int {
+	n := 0
+	for _, inv := range s.invocations(arn) {
+		n++
+		if s.expanded[inv.requestID] {
+			n += len(inv.events)
+		}
+	}
+	return n
+}
+
+// invocationRows returns the rows of the invocations view of arn.
+func (s *DisplayLogScreen) invocationRows(arn string) []invocationRow {
+	rows := []invocationRow{}
+	for _, inv := range s.invocations(arn) {
+		rows = append(rows, invocationRow{inv: inv})
+		if s.expanded[inv.requestID] {
+			for _, evt := range inv.events {
+				rows = append(rows, invocationRow{inv: inv, event: evt})
+			}
+		}
+	}
+	return rows
+}
+
+// renderInvocations writes the invocations view of arn to buf, in rows rows
+// of cols columns.
+func (s *DisplayLogScreen) renderInvocations(buf *bytes.Buffer, arn string, rows, cols int) {
+	all := s.invocationRows(arn)
+	cursor := min(s.invocationIndex[arn], max(len(all)-1, 0))
+	s.invocationIndex[arn] = cursor
+	top := visibleOffset(cursor, s.invocationTop[arn], rows, len(all))
+	s.invocationTop[arn] = top
+	for i := top; i < len(all) && i < top+rows; i++ {
+		row := all[i]
+		style := s.theme.Message
+		if i == cursor {
+			style = style.Merge(s.theme.Selected)
+		}
+		if row.event != nil {
+			line := "    " + s.timestamp(row.event) + " " + printable(strings.ReplaceAll(row.event.Message(), "\n", " "))
+			buf.WriteString(style.Paint(Truncate(line, cols, "...")) + "\n")
+			continue
+		}
+		inv := row.inv
+		marker := "▸ "
+		if s.expanded[inv.requestID] {
+			marker = "▾ "
+		}
+		line := marker + s.timestamp(inv.events[0]) + " " + inv.requestID
+		detail := " running"
+		if inv.reported {
+			detail = fmt.Sprintf(" %s (billed %s) %d/%dMB", formatDuration(inv.report.duration), formatDuration(inv.report.billed), inv.report.memoryUsed, inv.report.memorySize)
+			if inv.cold() {
+				detail += " cold " + formatDuration(inv.report.initDuration)
+			}
+		}
+		status, statusStyle := "", s.theme.Done
+		switch {
+		case inv.failed && inv.report.status == "timeout":
+			status, statusStyle = " timeout", s.theme.Error
+		case inv.failed:
+			status, statusStyle = " error", s.theme.Error
+		case inv.reported:
+			status = " ok"
+		}
+		if i == cursor {
+			statusStyle = statusStyle.Merge(s.theme.Selected)
+		}
+		text := Truncate(line+detail, max(cols-StringWidth(status), 0), "...")
+		buf.WriteString(style.Paint(text) + statusStyle.Paint(status) + "\n")
+	}
+}
+
+// moveInvocation moves the cursor of the invocations view by move rows.
+func (s *DisplayLogScreen) moveInvocation(move int) {
+	arn := s.log.ARN()
+	last := max(s.invocationCount(arn)-1, 0)
+	s.invocationIndex[arn] = min(max(s.invocationIndex[arn]+move, 0), last)
+	s.changed[arn] = true
+}
+
+// openInvocationRow expands or collapses the invocation under the cursor, or
+// shows the event under the cursor in the stream view.
+func (s *DisplayLogScreen) openInvocationRow() {
+	arn := s.log.ARN()
+	rows := s.invocationRows(arn)
+	if len(rows) == 0 {
+		return
+	}
+	row := rows[min(s.invocationIndex[arn], len(rows)-1)]
+	if row.event == nil {
+		s.expanded[row.inv.requestID] = !s.expanded[row.inv.requestID]
+		s.changed[arn] = true
+		return
+	}
+	s.grouped[arn] = false
+	s.jumpToEvent(s.log, row.event)
+}
+
+// handleInvocationAction handles action in the invocations view and reports
+// whether it is one of the view.
+func (s *DisplayLogScreen) handleInvocationAction(action Action) bool {
+	arn := s.log.ARN()
+	switch action {
+	case ActionUp:
+		s.moveInvocation(-1)
+	case ActionDown:
+		s.moveInvocation(1)
+	case ActionTop:
+		s.moveInvocation(-len(s.buffers[arn]))
+	case ActionBottom:
+		s.moveInvocation(len(s.buffers[arn]))
+	case ActionDisplayPageUp:
+		s.moveInvocation(-(s.paneRows(arn) - 2))
+	case ActionDisplayPageDown:
+		s.moveInvocation(s.paneRows(arn) - 2)
+	case ActionDisplayDetail:
+		s.openInvocationRow()
+	case ActionDisplayInvocations, ActionCancel:
+		s.grouped[arn] = false
+		s.changed[arn] = true
+	default:
+		return false
+	}
+	return true
+}
+
+// handleInvocationMouse scrolls the invocations view, and moves its cursor to
+// the row clicked at y, or opens the row under the cursor when clicked again.
+func (s *DisplayLogScreen) handleInvocationMouse(mouse MouseEvent, y int) {
+	arn := s.log.ARN()
+	switch mouse.Button {
+	case MouseWheelUp:
+		s.moveInvocation(-1)
+	case MouseWheelDown:
+		s.moveInvocation(1)
+	case MouseLeft:
+		if mouse.Release || mouse.Motion {
+			return
+		}
+		i := s.invocationTop[arn] + y - 2
+		if i == s.invocationIndex[arn] {
+			s.openInvocationRow()
+			return
+		}
+		s.moveInvocation(i - s.invocationIndex[arn])
+	}
+}
@@ -4,27 +4,89 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
-	"sync"
 	"time"
-	"unicode"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
 )
 
+type ProfileRegion struct {
+	Profile string
+	Region  string
+}
+
+// LinkedAccounts enables discovery of log groups of source accounts linked
+// to a monitoring account through CloudWatch cross-account observability.
+type LinkedAccounts struct {
+	MonitoringAccountID string
+	AccountIdentifiers  []string
+}
+
 type LogGroup struct {
-	client   *cloudwatchlogs.Client
+	client   Client
 	profile  string
+	account  string
+	region   string
+	via      string
 	LogGroup types.LogGroup
 }
 
+func NewLogGroup(client Client, profile string, logGroup types.LogGroup) (*LogGroup, error) {
+	return newLogGroup(client, profile, "", logGroup)
+}
+
+// newLogGroup returns a log group discovered with profile. via is the
+// monitoring account of the discovery, if linked accounts were included.
+//
+// The owning account and the region are those of the ARN of the described
+// log group, which names the source account of a linked log group. The ARN
+// with the ":*" suffix is used if the one without it is missing.
+func newLogGroup(client Client, profile, via string, logGroup types.LogGroup) (*LogGroup, error) {
+	if logGroup.LogGroupArn == nil && logGroup.Arn != nil {
+		logGroup.LogGroupArn = aws.String(strings.TrimSuffix(*logGroup.Arn, ":*"))
+	}
+	if logGroup.LogGroupArn == nil || logGroup.LogGroupName == nil {
+		return nil, errors.New("log group without ARN or name")
+	}
+	parsed, err := arn.Parse(*logGroup.LogGroupArn)
+	if err != nil {
+		return nil, fmt.Errorf("log group %s: %w", *logGroup.LogGroupName, err)
+	}
+	if parsed.AccountID == "" || parsed.Region == "" {
+		return nil, fmt.Errorf("log group %s: invalid ARN: %s", *logGroup.LogGroupName, *logGroup.LogGroupArn)
+	}
+	return &LogGroup{
+		client:   client,
+		profile:  profile,
+		account:  parsed.AccountID,
+		region:   parsed.Region,
+		via:      via,
+		LogGroup: logGroup,
+	}, nil
+}
+
 func (lg *LogGroup) Profile() string {
 	return lg.profile
 }
 
+// Linked reports whether the log group belongs to a source account and is
+// only reachable through the monitoring account of its profile.
+func (lg *LogGroup) Linked() bool {
+	return lg.via != "" && lg.via != lg.account
+}
+
+// MonitoringAccountID returns the monitoring account the log group was
+// discovered through, or "" if linked accounts were not included.
+func (lg *LogGroup) MonitoringAccountID() string {
+	return lg.via
+}
+
 func (lg *LogGroup) Name() string {
 	return *lg.LogGroup.LogGroupName
 }
@@ -33,102 +95,208 @@ func (lg *LogGroup) ARN() string {
 	return *lg.LogGroup.LogGroupArn
 }
 
+// AccountID returns the account owning the log group, which is the source
+// account for linked log groups.
 func (lg *LogGroup) AccountID() string {
-	arn := lg.ARN()
-	parts := strings.Split(arn, ":")
-	return parts[4]
+	return lg.account
 }
 
 func (lg *LogGroup) Region() string {
-	arn := lg.ARN()
-	parts := strings.Split(arn, ":")
-	return parts[3]
+	return lg.region
 }
 
-func (lg *LogGroup) Stream(ctx context.Context) (*cloudwatchlogs.StartLiveTailEventStream, error) {
-	output, err := lg.client.StartLiveTail(ctx, &cloudwatchlogs.StartLiveTailInput{
+// Stream starts a live tail of the log group. Only events matching pattern,
+// a CloudWatch Logs filter pattern, are sent unless it is empty.
+func (lg *LogGroup) Stream(ctx context.Context, pattern string) (*cloudwatchlogs.StartLiveTailEventStream, error) {
+	input := &cloudwatchlogs.StartLiveTailInput{
 		LogGroupIdentifiers: []string{lg.ARN()},
-	})
-	if err != nil {
-		return nil, err
 	}
-
-	return output.GetStream(), nil
+	if pattern != "" {
+		input.LogEventFilterPattern = aws.String(pattern)
+	}
+	return lg.client.LiveTail(ctx, input)
 }
 
-func GetLogGroups(ctx context.Context, cfgs map[string]aws.Config) ([]*LogGroup, error) {
-	m := make(map[string]struct{})
+// Events returns the events since the given time matching pattern, oldest
+// first. Only the last limit events are kept.
+func (lg *LogGroup) Events(ctx context.Context, since time.Time, pattern string, limit int) ([]*LogEvent, error) {
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupIdentifier: aws.String(lg.ARN()),
+		StartTime:          aws.Int64(since.UnixMilli()),
+	}
+	if pattern != "" {
+		input.FilterPattern = aws.String(pattern)
+	}
+	events := []*LogEvent{}
+	for {
+		output, err := lg.client.FilterLogEvents(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, evt := range output.Events {
+			events = append(events, NewLogEvent(types.LiveTailSessionLogEvent{
+				LogGroupIdentifier: aws.String(lg.ARN()),
+				LogStreamName:      evt.LogStreamName,
+				Message:            evt.Message,
+				Timestamp:          evt.Timestamp,
+			}))
+		}
+		if len(events) > limit {
+			events = events[len(events)-limit:]
+		}
+		if output.NextToken == nil {
+			return events, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
 
-	errs := []error{}
+// DescribeLogGroups pages through all log groups visible to client and calls
+// fn with each page. Log groups of linked source accounts are included when
+// linked is not nil.
+func DescribeLogGroups(ctx context.Context, client Client, profile string, linked *LinkedAccounts, fn func([]*LogGroup)) error {
+	via := ""
+	var nextToken *string
+	for {
+		input := &cloudwatchlogs.DescribeLogGroupsInput{
+			NextToken: nextToken,
+			Limit:     aws.Int32(50),
+		}
+		if linked != nil {
+			via = linked.MonitoringAccountID
+			input.IncludeLinkedAccounts = aws.Bool(true)
+			input.AccountIdentifiers = linked.AccountIdentifiers
+		}
+		output, err := client.DescribeLogGroups(ctx, input)
+		if err != nil {
+			return err
+		}
+		logGroups := make([]*LogGroup, 0, len(output.LogGroups))
+		for _, logGroup := range output.LogGroups {
+			// A log group that cannot be addressed is left out rather than
+			// failing the discovery of the others.
+			if lg, err := newLogGroup(client, profile, via, logGroup); err == nil {
+				logGroups = append(logGroups, lg)
+			}
+		}
+		fn(logGroups)
+		nextToken = output.NextToken
+		if nextToken == nil {
+			return nil
+		}
+	}
+}
 
+// GetCachedLogGroups builds log groups of profiles from the disk cache without
+// retrieving credentials, so they can be shown before discovery finishes.
+// Entries discovered through another endpoint than the current one are
+// skipped.
+func GetCachedLogGroups(ctx context.Context, cfg *Config, cache *LogGroupCache, profiles []string) []*LogGroup {
 	logGroups := []*LogGroup{}
-	wg := sync.WaitGroup{}
-	for profile, cfg := range cfgs {
-		client := cloudwatchlogs.NewFromConfig(cfg)
-		wg.Add(1)
-		go func(client *cloudwatchlogs.Client, profile string) {
-			defer wg.Done()
-			defer func() {
-				if err := recover(); err != nil {
-					fmt.Println(err)
-				}
-			}()
-			var nextToken *string
-			for {
-				output, err := client.DescribeLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
-					NextToken: nextToken,
-					Limit:     aws.Int32(50),
-				})
-				if err != nil {
-					errs = append(errs, err)
-					return
-				}
-				for _, logGroup := range output.LogGroups {
-					if _, ok := m[*logGroup.LogGroupArn]; ok {
-						continue
-					}
-					m[*logGroup.LogGroupArn] = struct{}{}
-					logGroups = append(logGroups, &LogGroup{
-						client:   client,
-						profile:  profile,
-						LogGroup: logGroup,
-					})
-				}
-				nextToken = output.NextToken
-				if nextToken == nil {
-					break
-				}
+	for _, entry := range cache.entries(profiles) {
+		if entry.EndpointURL != cfg.ProfileEndpointURL(entry.Profile) {
+			continue
+		}
+		opts := append(awsConfigOptions(entry.Profile), config.WithRegion(entry.Region))
+		awscfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			continue
+		}
+		client := cfg.NewClient(entry.Profile, awscfg)
+		for _, logGroup := range entry.LogGroups {
+			if lg, err := newLogGroup(client, entry.Profile, entry.MonitoringAccountID, logGroup); err == nil {
+				logGroups = append(logGroups, lg)
 			}
-		}(client, profile)
+		}
+	}
+
+	logGroups = dedupeLogGroups(logGroups)
+	sortLogGroups(logGroups)
+
+	return logGroups
+}
+
+// MergeLogGroups replaces the groups of every refreshed profile region with
+// the refreshed ones and keeps the current groups of those that were not.
+func MergeLogGroups(current, refreshed []*LogGroup, cfgs map[ProfileRegion]aws.Config) []*LogGroup {
+	logGroups := slices.Clone(refreshed)
+	for _, logGroup := range current {
+		if _, ok := cfgs[ProfileRegion{Profile: logGroup.Profile(), Region: logGroup.Region()}]; ok {
+			continue
+		}
+		logGroups = append(logGroups, logGroup)
 	}
-	wg.Wait()
 
-	if len(logGroups) == 0 {
-		if len(errs) > 0 {
-			return nil, errors.Join(errs...)
+	logGroups = dedupeLogGroups(logGroups)
+	sortLogGroups(logGroups)
+
+	return logGroups
+}
+
+// AppendLogGroups adds the found log groups that are not shown yet after the
+// current ones, without removing or reordering any.
+func AppendLogGroups(current, found []*LogGroup) []*LogGroup {
+	logGroups := make([]*LogGroup, 0, len(current)+len(found))
+	logGroups = append(logGroups, current...)
+	logGroups = append(logGroups, found...)
+
+	return dedupeLogGroups(logGroups)
+}
+
+// dedupeLogGroups keeps one log group per ARN. A log group reachable both
+// directly and through a monitoring account is kept as the direct one, whose
+// profile can read it without cross-account observability.
+func dedupeLogGroups(logGroups []*LogGroup) []*LogGroup {
+	m := make(map[string]int, len(logGroups))
+	deduped := make([]*LogGroup, 0, len(logGroups))
+	for _, logGroup := range logGroups {
+		i, ok := m[logGroup.ARN()]
+		if !ok {
+			m[logGroup.ARN()] = len(deduped)
+			deduped = append(deduped, logGroup)
+			continue
+		}
+		if deduped[i].Linked() && !logGroup.Linked() {
+			deduped[i] = logGroup
 		}
-		return nil, errors.New("no log groups found")
 	}
+	return deduped
+}
 
+func sortLogGroups(logGroups []*LogGroup) {
 	sort.Slice(logGroups, func(i, j int) bool {
 		if logGroups[i].AccountID() != logGroups[j].AccountID() {
 			return logGroups[i].AccountID() < logGroups[j].AccountID()
 		}
-		return *logGroups[i].LogGroup.LogGroupName < *logGroups[j].LogGroup.LogGroupName
+		if *logGroups[i].LogGroup.LogGroupName != *logGroups[j].LogGroup.LogGroupName {
+			return *logGroups[i].LogGroup.LogGroupName < *logGroups[j].LogGroup.LogGroupName
+		}
+		return logGroups[i].Region() < logGroups[j].Region()
 	})
-
-	return logGroups, nil
 }
 
 type LogEvent struct {
 	msg       string
+	stream    string
 	timestamp time.Time
+	level     int
+	// raw is the message as written, and folded the events that continue
+	// it, such as the lines of a stack trace. See Multiline.
+	raw    string
+	folded []*LogEvent
+	// lambda is the message parsed as a line of Lambda function logs, once
+	// the event is buffered for a Lambda group.
+	lambda *lambdaLine
 }
 
 func NewLogEvent(evt types.LiveTailSessionLogEvent) *LogEvent {
+	msg := strings.ReplaceAll(strings.TrimSpace(*evt.Message), "\t", " ")
 	return &LogEvent{
-		msg:       strings.ReplaceAll(strings.TrimSpace(*evt.Message), "\t", " "),
+		msg:       msg,
+		raw:       strings.TrimRight(*evt.Message, "\r\n"),
+		stream:    aws.ToString(evt.LogStreamName),
 		timestamp: time.UnixMilli(*evt.Timestamp).In(time.FixedZone("Asia/Tokyo", 9*60*60)),
+		level:     detectLevel(msg),
 	}
 }
 
@@ -140,24 +308,22 @@ func (e LogEvent) Message() string {
 	return e.msg
 }
 
-func (e LogEvent) Lines(col int) []string {
-	lines := []string{}
-	line := ""
-	for _, c := range e.msg {
-		if c == '\n' {
-			line += "\n"
-			lines = append(lines, line)
-			line = ""
-		} else if unicode.IsPrint(c) {
-			line += string(c)
-			if len(line) >= col {
-				lines = append(lines, line)
-				line = ""
-			}
-		}
-	}
-	if line != "" {
-		lines = append(lines, line)
+// LogStream returns the name of the log stream the event was written to.
+func (e LogEvent) LogStream() string {
+	return e.stream
+}
+
+// Detail returns the message as written followed by the lines folded into
+// it, with tabs expanded.
+func (e LogEvent) Detail() string {
+	lines := []string{e.raw}
+	for _, evt := range e.folded {
+		lines = append(lines, evt.raw)
 	}
-	return lines
+	return strings.ReplaceAll(strings.Join(lines, "\n"), "\t", "    ")
+}
+
+// Lines wraps the printable part of the message to col columns.
+func (e LogEvent) Lines(col int) []string {
+	return Wrap(printable(e.msg), col)
 }
@@ -0,0 +1,333 @@
+package cwl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func fakeLogGroups(account, region string, n int) []types.LogGroup {
+	logGroups := make([]types.LogGroup, n)
+	for i := range logGroups {
+		logGroups[i] = NewFakeLogGroup(account, region, fmt.Sprintf("/app/%02d", i))
+	}
+	return logGroups
+}
+
+func mustLogGroup(t *testing.T, client Client, profile, via string, logGroup types.LogGroup) *LogGroup {
+	t.Helper()
+	lg, err := newLogGroup(client, profile, via, logGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lg
+}
+
+func names(logGroups []*LogGroup) []string {
+	names := make([]string, len(logGroups))
+	for i, lg := range logGroups {
+		names[i] = lg.Profile() + ":" + lg.AccountID() + ":" + lg.Region() + ":" + lg.Name()
+	}
+	return names
+}
+
+func TestDescribeLogGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		logGroups []types.LogGroup
+		pageSize  int
+		account   string
+		linked    *LinkedAccounts
+		err       error
+		pages     []int
+		accounts  []string
+		wantErr   bool
+	}{
+		{
+			name:      "one page",
+			logGroups: fakeLogGroups("111111111111", "us-east-1", 3),
+			pageSize:  50,
+			pages:     []int{3},
+		},
+		{
+			name:      "pages",
+			logGroups: fakeLogGroups("111111111111", "us-east-1", 5),
+			pageSize:  2,
+			pages:     []int{2, 2, 1},
+		},
+		{
+			name:      "no log groups",
+			logGroups: nil,
+			pageSize:  2,
+			pages:     []int{0},
+		},
+		{
+			name:      "linked accounts left out",
+			logGroups: append(fakeLogGroups("111111111111", "us-east-1", 2), fakeLogGroups("222222222222", "us-east-1", 2)...),
+			pageSize:  50,
+			account:   "111111111111",
+			pages:     []int{2},
+			accounts:  []string{"111111111111"},
+		},
+		{
+			name:      "linked accounts included",
+			logGroups: append(fakeLogGroups("111111111111", "us-east-1", 2), fakeLogGroups("222222222222", "us-east-1", 2)...),
+			pageSize:  3,
+			account:   "111111111111",
+			linked:    &LinkedAccounts{MonitoringAccountID: "111111111111"},
+			pages:     []int{3, 1},
+			accounts:  []string{"111111111111", "222222222222"},
+		},
+		{
+			name: "unusable ARNs skipped",
+			logGroups: append(fakeLogGroups("111111111111", "us-east-1", 1),
+				types.LogGroup{LogGroupName: aws.String("no-arn")},
+				types.LogGroup{LogGroupName: aws.String("short"), LogGroupArn: aws.String("arn:aws:logs")},
+			),
+			pageSize: 50,
+			pages:    []int{1},
+		},
+		{
+			name:     "error",
+			err:      errors.New("throttled"),
+			pageSize: 50,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewFakeClient(tt.logGroups...)
+			client.PageSize = tt.pageSize
+			client.AccountID = tt.account
+			client.Err = tt.err
+
+			pages := []int{}
+			accounts := []string{}
+			err := DescribeLogGroups(context.Background(), client, "dev", tt.linked, func(page []*LogGroup) {
+				pages = append(pages, len(page))
+				for _, lg := range page {
+					if !slices.Contains(accounts, lg.AccountID()) {
+						accounts = append(accounts, lg.AccountID())
+					}
+					if lg.Profile() != "dev" {
+						t.Errorf("profile = %q, want dev", lg.Profile())
+					}
+					if tt.linked != nil && lg.MonitoringAccountID() != tt.linked.MonitoringAccountID {
+						t.Errorf("monitoring account = %q, want %q", lg.MonitoringAccountID(), tt.linked.MonitoringAccountID)
+					}
+				}
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !slices.Equal(pages, tt.pages) {
+				t.Errorf("pages = %v, want %v", pages, tt.pages)
+			}
+			if tt.accounts != nil && !slices.Equal(accounts, tt.accounts) {
+				t.Errorf("accounts = %v, want %v", accounts, tt.accounts)
+			}
+		})
+	}
+}
+
+func TestNewLogGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		logGroup types.LogGroup
+		via      string
+		account  string
+		region   string
+		linked   bool
+		wantErr  bool
+	}{
+		{
+			name:     "own account",
+			logGroup: NewFakeLogGroup("111111111111", "us-east-1", "/app"),
+			account:  "111111111111",
+			region:   "us-east-1",
+		},
+		{
+			name:     "source account",
+			logGroup: NewFakeLogGroup("222222222222", "eu-west-1", "/app"),
+			via:      "111111111111",
+			account:  "222222222222",
+			region:   "eu-west-1",
+			linked:   true,
+		},
+		{
+			name:     "monitoring account",
+			logGroup: NewFakeLogGroup("111111111111", "eu-west-1", "/app"),
+			via:      "111111111111",
+			account:  "111111111111",
+			region:   "eu-west-1",
+		},
+		{
+			name: "ARN with suffix only",
+			logGroup: types.LogGroup{
+				LogGroupName: aws.String("/app"),
+				Arn:          aws.String("arn:aws:logs:us-east-1:111111111111:log-group:/app:*"),
+			},
+			account: "111111111111",
+			region:  "us-east-1",
+		},
+		{
+			name:     "no ARN",
+			logGroup: types.LogGroup{LogGroupName: aws.String("/app")},
+			wantErr:  true,
+		},
+		{
+			name:     "no name",
+			logGroup: types.LogGroup{LogGroupArn: aws.String("arn:aws:logs:us-east-1:111111111111:log-group:/app")},
+			wantErr:  true,
+		},
+		{
+			name:     "short ARN",
+			logGroup: types.LogGroup{LogGroupName: aws.String("/app"), LogGroupArn: aws.String("arn:aws:logs")},
+			wantErr:  true,
+		},
+		{
+			name:     "ARN without account",
+			logGroup: types.LogGroup{LogGroupName: aws.String("/app"), LogGroupArn: aws.String("arn:aws:logs:us-east-1::log-group:/app")},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lg, err := newLogGroup(NewFakeClient(), "dev", tt.via, tt.logGroup)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if lg.AccountID() != tt.account || lg.Region() != tt.region || lg.Linked() != tt.linked {
+				t.Errorf("got %s %s linked %v, want %s %s linked %v", lg.AccountID(), lg.Region(), lg.Linked(), tt.account, tt.region, tt.linked)
+			}
+		})
+	}
+}
+
+func TestDedupeLogGroups(t *testing.T) {
+	client := NewFakeClient()
+	own := NewFakeLogGroup("111111111111", "us-east-1", "/a")
+	source := NewFakeLogGroup("222222222222", "us-east-1", "/b")
+	direct := mustLogGroup(t, client, "source", "", source)
+	linked := mustLogGroup(t, client, "monitoring", "111111111111", source)
+
+	tests := []struct {
+		name      string
+		logGroups []*LogGroup
+		want      []string
+	}{
+		{
+			name:      "empty",
+			logGroups: nil,
+			want:      []string{},
+		},
+		{
+			name: "same profile twice",
+			logGroups: []*LogGroup{
+				mustLogGroup(t, client, "dev", "", own),
+				mustLogGroup(t, client, "dev", "", own),
+			},
+			want: []string{"dev:111111111111:us-east-1:/a"},
+		},
+		{
+			name:      "direct replaces linked",
+			logGroups: []*LogGroup{linked, direct},
+			want:      []string{"source:222222222222:us-east-1:/b"},
+		},
+		{
+			name:      "linked does not replace direct",
+			logGroups: []*LogGroup{direct, linked},
+			want:      []string{"source:222222222222:us-east-1:/b"},
+		},
+		{
+			name: "order kept",
+			logGroups: []*LogGroup{
+				mustLogGroup(t, client, "dev", "", source),
+				mustLogGroup(t, client, "dev", "", own),
+			},
+			want: []string{"dev:222222222222:us-east-1:/b", "dev:111111111111:us-east-1:/a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(dedupeLogGroups(tt.logGroups)); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeLogGroups(t *testing.T) {
+	client := NewFakeClient()
+	lg := func(profile, region, name string) *LogGroup {
+		return mustLogGroup(t, client, profile, "", NewFakeLogGroup("111111111111", region, name))
+	}
+	refreshed := func(keys ...ProfileRegion) map[ProfileRegion]aws.Config {
+		cfgs := make(map[ProfileRegion]aws.Config, len(keys))
+		for _, key := range keys {
+			cfgs[key] = aws.Config{}
+		}
+		return cfgs
+	}
+
+	tests := []struct {
+		name      string
+		current   []*LogGroup
+		refreshed []*LogGroup
+		cfgs      map[ProfileRegion]aws.Config
+		want      []string
+	}{
+		{
+			name:      "first discovery",
+			refreshed: []*LogGroup{lg("dev", "us-east-1", "/b"), lg("dev", "us-east-1", "/a")},
+			cfgs:      refreshed(ProfileRegion{"dev", "us-east-1"}),
+			want:      []string{"dev:111111111111:us-east-1:/a", "dev:111111111111:us-east-1:/b"},
+		},
+		{
+			name:      "deleted group dropped",
+			current:   []*LogGroup{lg("dev", "us-east-1", "/a"), lg("dev", "us-east-1", "/gone")},
+			refreshed: []*LogGroup{lg("dev", "us-east-1", "/a")},
+			cfgs:      refreshed(ProfileRegion{"dev", "us-east-1"}),
+			want:      []string{"dev:111111111111:us-east-1:/a"},
+		},
+		{
+			name:      "failed region kept",
+			current:   []*LogGroup{lg("dev", "us-east-1", "/a"), lg("dev", "eu-west-1", "/kept")},
+			refreshed: []*LogGroup{lg("dev", "us-east-1", "/b")},
+			cfgs:      refreshed(ProfileRegion{"dev", "us-east-1"}),
+			want:      []string{"dev:111111111111:us-east-1:/b", "dev:111111111111:eu-west-1:/kept"},
+		},
+		{
+			name:      "empty region refreshed",
+			current:   []*LogGroup{lg("dev", "us-east-1", "/a")},
+			refreshed: nil,
+			cfgs:      refreshed(ProfileRegion{"dev", "us-east-1"}),
+			want:      []string{},
+		},
+		{
+			name:      "duplicates across profiles",
+			current:   []*LogGroup{lg("ops", "us-east-1", "/a")},
+			refreshed: []*LogGroup{lg("dev", "us-east-1", "/a")},
+			cfgs:      refreshed(ProfileRegion{"dev", "us-east-1"}),
+			want:      []string{"dev:111111111111:us-east-1:/a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(MergeLogGroups(tt.current, tt.refreshed, tt.cfgs)); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
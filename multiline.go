@@ -0,0 +1,209 @@
+package cwl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// DefaultMultilinePatterns match the lines that continue the event before
+// them: indented lines such as the frames of Java and Python stack traces,
+// and the lines that start or end a trace.
+var DefaultMultilinePatterns = []string{
+	`^\s`,
+	`^Caused by: `,
+	`^Traceback \(most recent call last\):`,
+	`^\.\.\. \d+ more`,
+	`^[\w.$]+(Error|Exception)(: |$)`,
+}
+
+// DefaultMultilineWindow is how long after an event its continuation lines
+// can be written.
+const DefaultMultilineWindow = time.Second
+
+// maxFoldedLines is the most lines folded into one event. Further lines start
+// an event of their own, so that a stream of indented lines cannot grow an
+// event without end.
+const maxFoldedLines = 200
+
+// Multiline folds the events that continue an event, such as the lines of a
+// stack trace logged one by one, into that event.
+type Multiline struct {
+	patterns []*regexp.Regexp
+	window   time.Duration
+}
+
+// DefaultMultiline folds the lines of DefaultMultilinePatterns written within
+// DefaultMultilineWindow.
+var DefaultMultiline = must(NewMultiline(nil, DefaultMultilineWindow))
+
+// NewMultiline returns a multiline of patterns, or DefaultMultilinePatterns
+// if there are none, that folds events written within window of the event
+// they continue.
+func NewMultiline(patterns []string, window time.Duration) (*Multiline, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultMultilinePatterns
+	}
+	m := &Multiline{window: window}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiline pattern %q: %w", pattern, err)
+		}
+		m.patterns = append(m.patterns, re)
+	}
+	return m, nil
+}
+
+// MultilineConfig configures the folding of events into the event they
+// continue. Window is a duration such as "500ms".
+type MultilineConfig struct {
+	Disabled bool     `json:"disabled"`
+	Patterns []string `json:"patterns"`
+	Window   string   `json:"window"`
+}
+
+// LoadMultiline returns the multiline of the config.
+func (c *Config) LoadMultiline() (*Multiline, error) {
+	window := DefaultMultilineWindow
+	if c.Multiline.Window != "" {
+		var err error
+		window, err = time.ParseDuration(c.Multiline.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiline window: %w", err)
+		}
+	}
+	return NewMultiline(c.Multiline.Patterns, window)
+}
+
+// continues reports whether evt continues head, from the same stream, within
+// the window of head and while head has room for it.
+func (m *Multiline) continues(head, evt *LogEvent) bool {
+	if evt.stream != head.stream || evt.timestamp.Sub(head.timestamp) > m.window {
+		return false
+	}
+	if len(head.folded) >= maxFoldedLines {
+		return false
+	}
+	return slices.ContainsFunc(m.patterns, func(re *regexp.Regexp) bool {
+		return re.MatchString(evt.raw)
+	})
+}
+
+// append appends evt to events, or folds it into the last of them if it
+// continues it.
+func (m *Multiline) append(events []*LogEvent, evt *LogEvent) []*LogEvent {
+	if n := len(events); n > 0 && m.continues(events[n-1], evt) {
+		events[n-1].folded = append(events[n-1].folded, evt)
+		return events
+	}
+	return append(events, evt)
+}
+
+// fold folds the events that continue others, which must not be folded yet.
+func (m *Multiline) fold(events []*LogEvent) []*LogEvent {
+	folded := make([]*LogEvent, 0, len(events))
+	for _, evt := range events {
+		folded = m.append(folded, evt)
+	}
+	return folded
+}
+
+// unfold returns events with the events folded into them after them, and
+// clears their folded events.
+func unfold(events []*LogEvent) []*LogEvent {
+	unfolded := make([]*LogEvent, 0, len(events))
+	for _, evt := range events {
+		unfolded = append(unfolded, evt)
+		unfolded = append(unfolded, evt.folded...)
+		evt.folded = nil
+	}
+	return unfolded
+}
+
+// countLines returns the number of events of events, the folded ones
+// included.
+func countLines(events []*LogEvent) int {
+	n := len(events)
+	for _, evt := range events {
+		n += len(evt.folded)
+	}
+	return n
+}
+
+// trimLines drops the oldest events of events until they hold at most limit
+// events, the folded ones included.
+func trimLines(events []*LogEvent, limit int) []*LogEvent {
+	n := countLines(events)
+	i := 0
+	for ; i < len(events) && n > limit; i++ {
+		n -= 1 + len(events[i].folded)
+	}
+	return events[i:]
+}
+
+// indexOf returns the index of the event of events that is evt or has evt
+// folded into it, or -1.
+func indexOf(events []*LogEvent, evt *LogEvent) int {
+	return slices.IndexFunc(events, func(e *LogEvent) bool {
+		return e == evt || slices.Contains(e.folded, evt)
+	})
+}
+
+// appendEvent appends evt to the buffer of arn, folding it if fold is on, and
+// groups it into its invocation if arn is of a Lambda group.
+func (s *DisplayLogScreen) appendEvent(arn string, evt *LogEvent) {
+	events := s.buffers[arn]
+	n := len(events)
+	if s.fold {
+		events = s.multiline.append(events, evt)
+	} else {
+		events = append(events, evt)
+	}
+	s.buffers[arn] = events
+	if x := s.lambdas[arn]; x != nil && len(events) > n {
+		x.add(evt)
+	}
+}
+
+// trimBuffer drops the oldest events of arn beyond MaxEvents, and summarizes
+// the invocations left if arn is of a Lambda group.
+func (s *DisplayLogScreen) trimBuffer(arn string) {
+	events := s.buffers[arn]
+	trimmed := trimLines(events, MaxEvents)
+	s.buffers[arn] = trimmed
+	if x := s.lambdas[arn]; x != nil {
+		for _, evt := range events[:len(events)-len(trimmed)] {
+			x.drop(evt)
+		}
+		x.summary = summarize(x.invs)
+	}
+}
+
+// setFold folds or unfolds the events of every group, keeping the cursors on
+// their events.
+func (s *DisplayLogScreen) setFold(on bool) {
+	s.fold = on
+	for _, log := range s.logs {
+		arn := log.ARN()
+		events := s.buffers[arn]
+		var current *LogEvent
+		if idx := s.index[arn]; 0 <= idx && idx < len(events) {
+			current = events[idx]
+		}
+		events = unfold(events)
+		if on {
+			events = s.multiline.fold(events)
+		}
+		events = trimLines(events, MaxEvents)
+		s.buffers[arn] = events
+		s.indexInvocations(arn)
+		if current != nil {
+			s.index[arn] = max(indexOf(events, current), 0)
+			s.offset[arn] = visibleOffset(s.index[arn], s.offset[arn], s.listRows(arn), len(events))
+		}
+		delete(s.selection, arn)
+		s.changed[arn] = true
+	}
+}
@@ -0,0 +1,363 @@
+package cwl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type ProfileStatus int
+
+const (
+	ProfileStatusPending ProfileStatus = iota
+	ProfileStatusCredentials
+	ProfileStatusDiscovering
+	ProfileStatusDone
+	ProfileStatusFailed
+	ProfileStatusLoginRequired
+)
+
+func (s ProfileStatus) String() string {
+	switch s {
+	case ProfileStatusPending:
+		return "pending"
+	case ProfileStatusCredentials:
+		return "credentials"
+	case ProfileStatusDiscovering:
+		return "discovering"
+	case ProfileStatusDone:
+		return "done"
+	case ProfileStatusFailed:
+		return "failed"
+	case ProfileStatusLoginRequired:
+		return "login required"
+	}
+	return "unknown"
+}
+
+type RegionProgress struct {
+	Region    string
+	LogGroups int
+	Err       error
+}
+
+type ProfileProgress struct {
+	Profile    string
+	Status     ProfileStatus
+	Regions    []RegionProgress
+	LogGroups  int
+	SSOSession *SSOSession
+	Err        error
+}
+
+func (pp *ProfileProgress) region(region string) *RegionProgress {
+	for i := range pp.Regions {
+		if pp.Regions[i].Region == region {
+			return &pp.Regions[i]
+		}
+	}
+	pp.Regions = append(pp.Regions, RegionProgress{Region: region})
+	return &pp.Regions[len(pp.Regions)-1]
+}
+
+// LoadProgress tracks log group discovery per profile. onChange is called
+// without holding the progress lock whenever something changes.
+type LoadProgress struct {
+	mu       sync.Mutex
+	profiles []*ProfileProgress
+	logs     []*LogGroup
+	arns     map[string]int
+	onChange func()
+}
+
+func NewLoadProgress(onChange func()) *LoadProgress {
+	return &LoadProgress{
+		arns:     make(map[string]int),
+		onChange: onChange,
+	}
+}
+
+func (p *LoadProgress) update(profile string, fn func(pp *ProfileProgress)) {
+	p.mu.Lock()
+	var pp *ProfileProgress
+	for _, v := range p.profiles {
+		if v.Profile == profile {
+			pp = v
+			break
+		}
+	}
+	if pp == nil {
+		pp = &ProfileProgress{Profile: profile}
+		p.profiles = append(p.profiles, pp)
+	}
+	fn(pp)
+	p.mu.Unlock()
+
+	if p.onChange != nil {
+		p.onChange()
+	}
+}
+
+func (p *LoadProgress) Add(profile string) {
+	p.update(profile, func(pp *ProfileProgress) {})
+}
+
+func (p *LoadProgress) SetStatus(profile string, status ProfileStatus) {
+	p.update(profile, func(pp *ProfileProgress) {
+		pp.Status = status
+	})
+}
+
+func (p *LoadProgress) SetRegions(profile string, regions []string) {
+	p.update(profile, func(pp *ProfileProgress) {
+		for _, region := range regions {
+			pp.region(region)
+		}
+	})
+}
+
+func (p *LoadProgress) Fail(profile string, err error) {
+	p.update(profile, func(pp *ProfileProgress) {
+		pp.Status = ProfileStatusFailed
+		pp.Err = err
+	})
+}
+
+// LoginRequired marks profile as failed because its SSO session has to be
+// signed in again.
+func (p *LoadProgress) LoginRequired(profile string, session *SSOSession, err error) {
+	p.update(profile, func(pp *ProfileProgress) {
+		pp.Status = ProfileStatusLoginRequired
+		pp.SSOSession = session
+		pp.Err = err
+	})
+}
+
+// Warn records err of profile without failing it, such as a step that was
+// skipped.
+func (p *LoadProgress) Warn(profile string, err error) {
+	p.update(profile, func(pp *ProfileProgress) {
+		pp.Err = err
+	})
+}
+
+func (p *LoadProgress) FailRegion(profile, region string, err error) {
+	p.update(profile, func(pp *ProfileProgress) {
+		pp.region(region).Err = err
+	})
+}
+
+func (p *LoadProgress) Found(profile, region string, logs []*LogGroup) {
+	p.update(profile, func(pp *ProfileProgress) {
+		rp := pp.region(region)
+		for _, log := range logs {
+			if i, ok := p.arns[log.ARN()]; ok {
+				if p.logs[i].Linked() && !log.Linked() {
+					p.logs[i] = log
+				}
+				continue
+			}
+			p.arns[log.ARN()] = len(p.logs)
+			p.logs = append(p.logs, log)
+			pp.LogGroups++
+			rp.LogGroups++
+		}
+	})
+}
+
+// Profiles returns a snapshot of the progress of every profile.
+func (p *LoadProgress) Profiles() []ProfileProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	profiles := make([]ProfileProgress, 0, len(p.profiles))
+	for _, pp := range p.profiles {
+		v := *pp
+		v.Regions = slices.Clone(pp.Regions)
+		profiles = append(profiles, v)
+	}
+	return profiles
+}
+
+// SSOSessions returns the SSO sessions that have to be signed in again, each
+// with all profiles waiting for it.
+func (p *LoadProgress) SSOSessions() []*SSOSession {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sessions := []*SSOSession{}
+	for _, pp := range p.profiles {
+		if pp.Status != ProfileStatusLoginRequired || pp.SSOSession == nil {
+			continue
+		}
+		sessions = AddSSOSession(sessions, pp.SSOSession)
+	}
+	return sessions
+}
+
+// LogGroups returns the log groups found so far, in the order they were
+// found.
+func (p *LoadProgress) LogGroups() []*LogGroup {
+	return p.LogGroupsSince(0)
+}
+
+// LogGroupsSince returns the log groups found after the first n.
+func (p *LoadProgress) LogGroupsSince(n int) []*LogGroup {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.logs[min(n, len(p.logs)):])
+}
+
+func (p *LoadProgress) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	errs := []error{}
+	for _, pp := range p.profiles {
+		if pp.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", pp.Profile, pp.Err))
+		}
+		for _, rp := range pp.Regions {
+			if rp.Err != nil {
+				errs = append(errs, fmt.Errorf("%s/%s: %w", pp.Profile, rp.Region, rp.Err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *LoadProgress) Summary() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	finished, failed := 0, 0
+	for _, pp := range p.profiles {
+		switch pp.Status {
+		case ProfileStatusDone:
+			finished++
+		case ProfileStatusFailed, ProfileStatusLoginRequired:
+			finished++
+			failed++
+		}
+	}
+	summary := fmt.Sprintf("%d/%d profiles, %d log groups", finished, len(p.profiles), len(p.logs))
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d failed", failed)
+	}
+	return summary
+}
+
+// DiscoverLogGroups loads every profile and describes its log groups in each
+// of its regions as soon as its credentials are available, reporting each step
+// to progress. At most cfg.DiscoveryConcurrency regions are described at
+// once. The returned configs only contain the profile regions whose discovery
+// succeeded.
+func DiscoverLogGroups(ctx context.Context, cfg *Config, profiles []string, progress *LoadProgress) (map[ProfileRegion]aws.Config, []*LogGroup, error) {
+	for _, profile := range profiles {
+		progress.Add(profile)
+	}
+
+	configs := make(map[ProfileRegion]aws.Config, len(profiles))
+	sem := make(chan struct{}, cfg.DiscoveryConcurrency())
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, profile := range profiles {
+		wg.Add(1)
+		go func(profile string) {
+			defer wg.Done()
+			defer func() {
+				if err := recover(); err != nil {
+					progress.Fail(profile, fmt.Errorf("%v", err))
+				}
+			}()
+			progress.SetStatus(profile, ProfileStatusCredentials)
+			awscfg, err := LoadAWSConfig(ctx, profile)
+			if err != nil {
+				if session, _ := LoadSSOSession(ctx, profile); session != nil && session.loginRequired(err) {
+					progress.LoginRequired(profile, session, err)
+					return
+				}
+				progress.Fail(profile, err)
+				return
+			}
+			var linked *LinkedAccounts
+			if include, accounts := cfg.ProfileLinkedAccounts(profile); include {
+				// Linked log groups cannot be told apart without the
+				// monitoring account, so only the own ones are described
+				// if it cannot be looked up.
+				accountID, err := callerAccountID(ctx, awscfg)
+				if err != nil {
+					progress.Warn(profile, fmt.Errorf("linked accounts left out: %w", err))
+				} else {
+					linked = &LinkedAccounts{
+						MonitoringAccountID: accountID,
+						AccountIdentifiers:  accounts,
+					}
+				}
+			}
+
+			regions := cfg.ProfileRegions(profile, awscfg.Region)
+			progress.SetRegions(profile, regions)
+			progress.SetStatus(profile, ProfileStatusDiscovering)
+
+			failed := 0
+			rwg := sync.WaitGroup{}
+			for _, region := range regions {
+				rwg.Add(1)
+				go func(region string) {
+					defer rwg.Done()
+					sem <- struct{}{}
+					defer func() { <-sem }()
+
+					rcfg := awscfg.Copy()
+					rcfg.Region = region
+					client := cfg.NewClient(profile, rcfg)
+					err := DescribeLogGroups(ctx, client, profile, linked, func(logs []*LogGroup) {
+						progress.Found(profile, region, logs)
+					})
+					if err != nil {
+						progress.FailRegion(profile, region, err)
+						mu.Lock()
+						failed++
+						mu.Unlock()
+						return
+					}
+					mu.Lock()
+					configs[ProfileRegion{Profile: profile, Region: region}] = rcfg
+					mu.Unlock()
+				}(region)
+			}
+			rwg.Wait()
+
+			if failed == len(regions) {
+				progress.Fail(profile, errors.New("all regions failed"))
+				return
+			}
+			progress.SetStatus(profile, ProfileStatusDone)
+		}(profile)
+	}
+	wg.Wait()
+
+	logs := progress.LogGroups()
+	if len(logs) == 0 {
+		if err := progress.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("no log groups found")
+	}
+
+	return configs, logs, nil
+}
+
+// callerAccountID returns the account of the credentials of awscfg. STS is
+// reached at its default endpoint, or the one of the shared config or the
+// environment, as the endpoint URL of the config is the CloudWatch Logs one.
+func callerAccountID(ctx context.Context, awscfg aws.Config) (string, error) {
+	client := sts.NewFromConfig(awscfg)
+	identity, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(identity.Account), nil
+}
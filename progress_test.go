@@ -0,0 +1,260 @@
+package cwl
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+)
+
+const testSharedConfig = `[profile dev]
+region = us-east-1
+
+[profile broken]
+region = us-east-1
+credential_process = false
+
+[profile sso]
+region = us-east-1
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = ReadOnly
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+`
+
+const testSharedCredentials = `[dev]
+aws_access_key_id = AKIDEXAMPLE
+aws_secret_access_key = secret
+`
+
+// setupSharedConfig points the SDK at the profiles of testSharedConfig and
+// at an empty home, where the SSO token cache is, and returns the home.
+func setupSharedConfig(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	config := filepath.Join(home, "config")
+	credentials := filepath.Join(home, "credentials")
+	if err := os.WriteFile(config, []byte(testSharedConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(credentials, []byte(testSharedCredentials), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_CONFIG_FILE", config)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	for _, env := range []string{"AWS_PROFILE", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_REGION", "AWS_DEFAULT_REGION"} {
+		t.Setenv(env, "")
+	}
+	return home
+}
+
+// storeTestToken writes a token of the corp session that expires at expiresAt
+// to the SSO token cache.
+func storeTestToken(t *testing.T, expiresAt time.Time) {
+	t.Helper()
+	session := &SSOSession{Key: "corp", Name: "corp", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1"}
+	err := session.storeToken(ssoCachedToken{
+		AccessToken: "token",
+		ExpiresAt:   expiresAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverLogGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles []string
+		failing  []string
+		token    time.Duration
+		status   map[string]ProfileStatus
+		regions  []ProfileRegion
+		found    int
+		sessions []string
+		// linked includes linked accounts, whose caller lookup fails.
+		linked  bool
+		wantErr bool
+	}{
+		{
+			name:     "all regions",
+			profiles: []string{"dev"},
+			status:   map[string]ProfileStatus{"dev": ProfileStatusDone},
+			regions:  []ProfileRegion{{"dev", "eu-west-1"}, {"dev", "us-east-1"}},
+			found:    4,
+		},
+		{
+			name:     "region failure",
+			profiles: []string{"dev"},
+			failing:  []string{"eu-west-1"},
+			status:   map[string]ProfileStatus{"dev": ProfileStatusDone},
+			regions:  []ProfileRegion{{"dev", "us-east-1"}},
+			found:    2,
+		},
+		{
+			name:     "all regions fail",
+			profiles: []string{"dev"},
+			failing:  []string{"eu-west-1", "us-east-1"},
+			status:   map[string]ProfileStatus{"dev": ProfileStatusFailed},
+			wantErr:  true,
+		},
+		{
+			name:     "credentials failure",
+			profiles: []string{"dev", "broken"},
+			status:   map[string]ProfileStatus{"dev": ProfileStatusDone, "broken": ProfileStatusFailed},
+			regions:  []ProfileRegion{{"dev", "eu-west-1"}, {"dev", "us-east-1"}},
+			found:    4,
+		},
+		{
+			name:     "login required without token",
+			profiles: []string{"dev", "sso"},
+			status:   map[string]ProfileStatus{"dev": ProfileStatusDone, "sso": ProfileStatusLoginRequired},
+			regions:  []ProfileRegion{{"dev", "eu-west-1"}, {"dev", "us-east-1"}},
+			found:    4,
+			sessions: []string{"corp"},
+		},
+		{
+			name:     "caller lookup failure",
+			profiles: []string{"dev"},
+			linked:   true,
+			status:   map[string]ProfileStatus{"dev": ProfileStatusDone},
+			regions:  []ProfileRegion{{"dev", "eu-west-1"}, {"dev", "us-east-1"}},
+			found:    4,
+		},
+		{
+			name:     "login required with expired token",
+			profiles: []string{"sso"},
+			token:    -time.Hour,
+			status:   map[string]ProfileStatus{"sso": ProfileStatusLoginRequired},
+			sessions: []string{"corp"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupSharedConfig(t)
+			if tt.token != 0 {
+				storeTestToken(t, time.Now().Add(tt.token))
+			}
+			if tt.linked {
+				t.Setenv("AWS_ENDPOINT_URL_STS", "http://127.0.0.1:1")
+				t.Setenv("AWS_MAX_ATTEMPTS", "1")
+			}
+			cfg := &Config{
+				Regions:               []string{"us-east-1", "eu-west-1"},
+				IncludeLinkedAccounts: tt.linked,
+				newClient: func(profile string, awscfg aws.Config) Client {
+					client := NewFakeClient(fakeLogGroups("111111111111", awscfg.Region, 2)...)
+					if slices.Contains(tt.failing, awscfg.Region) {
+						client.Err = errors.New("access denied")
+					}
+					return client
+				},
+			}
+
+			progress := NewLoadProgress(nil)
+			cfgs, logs, err := DiscoverLogGroups(context.Background(), cfg, tt.profiles, progress)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+
+			for _, pp := range progress.Profiles() {
+				if pp.Status != tt.status[pp.Profile] {
+					t.Errorf("%s: status = %s, want %s (%v)", pp.Profile, pp.Status, tt.status[pp.Profile], pp.Err)
+				}
+				if tt.linked && pp.Err == nil {
+					t.Errorf("%s: no error of the caller lookup", pp.Profile)
+				}
+				for _, rp := range pp.Regions {
+					if failed := slices.Contains(tt.failing, rp.Region); (rp.Err != nil) != failed {
+						t.Errorf("%s/%s: err = %v, want error %v", pp.Profile, rp.Region, rp.Err, failed)
+					}
+				}
+			}
+			regions := []ProfileRegion{}
+			for key := range cfgs {
+				regions = append(regions, key)
+			}
+			slices.SortFunc(regions, func(a, b ProfileRegion) int {
+				return cmp.Or(cmp.Compare(a.Profile, b.Profile), cmp.Compare(a.Region, b.Region))
+			})
+			if len(regions) > 0 || len(tt.regions) > 0 {
+				if !slices.Equal(regions, tt.regions) {
+					t.Errorf("regions = %v, want %v", regions, tt.regions)
+				}
+			}
+			if len(logs) != tt.found {
+				t.Errorf("found %d log groups, want %d", len(logs), tt.found)
+			}
+			sessions := []string{}
+			for _, session := range progress.SSOSessions() {
+				sessions = append(sessions, session.Key)
+			}
+			if len(sessions) > 0 || len(tt.sessions) > 0 {
+				if !slices.Equal(sessions, tt.sessions) {
+					t.Errorf("sessions = %v, want %v", sessions, tt.sessions)
+				}
+			}
+		})
+	}
+}
+
+func TestSSOSessionLoginRequired(t *testing.T) {
+	tests := []struct {
+		name  string
+		token time.Duration
+		err   error
+		want  bool
+	}{
+		{
+			name: "no token",
+			err:  errors.New("failed to read cached SSO token file"),
+			want: true,
+		},
+		{
+			name:  "expired token",
+			token: -time.Minute,
+			err:   errors.New("cached SSO token is expired"),
+			want:  true,
+		},
+		{
+			name:  "valid token, network error",
+			token: time.Hour,
+			err:   errors.New("dial tcp: lookup portal.sso.us-east-1.amazonaws.com: no such host"),
+			want:  false,
+		},
+		{
+			name:  "valid token, invalid token error",
+			token: time.Hour,
+			err:   fmt.Errorf("get credentials: %w", &ssocreds.InvalidTokenError{}),
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupSharedConfig(t)
+			if tt.token != 0 {
+				storeTestToken(t, time.Now().Add(tt.token))
+			}
+			session, err := LoadSSOSession(context.Background(), "sso")
+			if err != nil || session == nil {
+				t.Fatalf("LoadSSOSession = %v, %v", session, err)
+			}
+			if got := session.loginRequired(tt.err); got != tt.want {
+				t.Errorf("loginRequired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
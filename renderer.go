@@ -0,0 +1,202 @@
+package cwl
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	SyncBegin = "\x1b[?2026h"
+	SyncEnd   = "\x1b[?2026l"
+)
+
+// Renderer is the Terminal screens draw into. Drawing goes to a back buffer,
+// and Flush sends only the cells that differ from the previous frame to the
+// wrapped terminal, as one synchronized update. Everything but drawing is
+// passed through.
+//
+// Rows kept with Reserve are left out of the size screens see, and an overlay
+// is drawn over the frame without touching the back buffer, so that the
+// screen shows again when it is removed.
+type Renderer struct {
+	Terminal
+	back     *VirtualTerminal
+	front    [][]Cell
+	reserved int
+	overlay  *VirtualTerminal
+	top      int
+	left     int
+}
+
+func NewRenderer(t Terminal) *Renderer {
+	return &Renderer{
+		Terminal: t,
+		back:     NewVirtualTerminal(1, 1),
+	}
+}
+
+// Invalidate makes the next Flush redraw the whole screen.
+func (r *Renderer) Invalidate() {
+	r.front = nil
+}
+
+// Begin prepares the back buffer for a frame, resizing it to the terminal.
+// Screens that do not redraw leave the previous frame in place.
+func (r *Renderer) Begin() error {
+	rows, cols, _, _, err := r.Terminal.Size()
+	if err != nil {
+		return err
+	}
+	if brows, bcols, _, _, _ := r.back.Size(); brows != rows || bcols != cols {
+		r.back = NewVirtualTerminal(rows, cols)
+		r.front = nil
+	}
+	return nil
+}
+
+// Reserve keeps the bottom n rows out of the size reported to screens, for
+// Footer.
+func (r *Renderer) Reserve(n int) {
+	r.reserved = n
+}
+
+// Footer writes line on the last row of the frame.
+func (r *Renderer) Footer(line string) error {
+	rows, cols, _, _, _ := r.back.Size()
+	return r.back.WriteString(CursorMove+ScreenClearLine+"%s"+ScreenReset, rows, 1, Truncate(line, cols, ""))
+}
+
+// Overlay draws lines in a box with title centered over the frame, until it
+// is called with no lines.
+func (r *Renderer) Overlay(title string, lines []string) {
+	if len(lines) == 0 {
+		r.overlay = nil
+		return
+	}
+	rows, cols, _, _, _ := r.back.Size()
+	width := StringWidth(title) + 4
+	for _, line := range lines {
+		width = max(width, StringWidth(line))
+	}
+	width = min(width+4, cols)
+	height := min(len(lines)+2, rows)
+	r.top, r.left = (rows-height)/2, (cols-width)/2
+
+	border := strings.Repeat("─", max(width-2, 0))
+	r.overlay = NewVirtualTerminal(height, width)
+	r.overlay.WriteString("┌%s┐", Truncate("─ "+title+" "+border, width-2, ""))
+	for i := 1; i < height-1; i++ {
+		r.overlay.WriteString(CursorMove+"│ %s"+ScreenReset+CursorMove+"│", i+1, 1, Truncate(lines[i-1], width-4, "…"), i+1, width)
+	}
+	r.overlay.WriteString(CursorMove+"└%s┘", height, 1, border)
+}
+
+// cell returns the cell of the frame at row and col, taking it from the
+// overlay where there is one.
+func (r *Renderer) cell(row, col int) Cell {
+	if r.overlay == nil {
+		return r.back.Cell(row, col)
+	}
+	rows, cols, _, _, _ := r.overlay.Size()
+	inside := func(col int) bool {
+		return r.top <= row && row < r.top+rows && r.left <= col && col < r.left+cols
+	}
+	if inside(col) {
+		return r.overlay.Cell(row-r.top, col-r.left)
+	}
+	// Wide characters cut by the edges of the overlay are blanked.
+	cell := r.back.Cell(row, col)
+	if cell.Rune == 0 && inside(col-1) {
+		return Cell{Rune: ' '}
+	}
+	if _, bcols, _, _, _ := r.back.Size(); col+1 < bcols && inside(col+1) && r.back.Cell(row, col+1).Rune == 0 {
+		return Cell{Rune: ' ', Style: cell.Style}
+	}
+	return cell
+}
+
+// Flush writes the changes of the frame since the last Flush.
+func (r *Renderer) Flush() error {
+	rows, cols, _, _, _ := r.back.Size()
+	b := strings.Builder{}
+	if r.front == nil {
+		b.WriteString(ScreenReset + ScreenClearAll)
+		r.front = r.back.blank(rows, cols)
+	}
+
+	cursor := [2]int{-1, -1}
+	style := Style{}
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			cell := r.cell(row, col)
+			if cell == r.front[row][col] {
+				continue
+			}
+			r.front[row][col] = cell
+			if cell.Rune == 0 {
+				// The right half of the wide character written before.
+				continue
+			}
+			if cursor != [2]int{row, col} {
+				b.WriteString(fmt.Sprintf(CursorMove, row+1, col+1))
+			}
+			if cell.Style != style {
+				b.WriteString(cell.Style.sgr())
+				style = cell.Style
+			}
+			b.WriteRune(cell.Rune)
+			b.WriteString(cell.Rest)
+			width := 1
+			if col+1 < cols && r.cell(row, col+1).Rune == 0 {
+				width = 2
+			}
+			cursor = [2]int{row, col + width}
+		}
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	return r.Terminal.WriteString("%s%s%s%s", SyncBegin, b.String(), ScreenReset, SyncEnd)
+}
+
+// sgr returns the escape sequence that switches from any style to s.
+func (s Style) sgr() string {
+	params := []string{"0"}
+	if s.Bold {
+		params = append(params, "1")
+	}
+	if s.Underline {
+		params = append(params, "4")
+	}
+	if s.Reverse {
+		params = append(params, "7")
+	}
+	if s.FG != "" {
+		params = append(params, s.FG)
+	}
+	if s.BG != "" {
+		params = append(params, s.BG)
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+func (r *Renderer) Write(p []byte) (int, error) {
+	return r.back.Write(p)
+}
+
+func (r *Renderer) WriteString(s string, args ...any) error {
+	return r.back.WriteString(s, args...)
+}
+
+func (r *Renderer) Size() (int, int, int, int, error) {
+	rows, cols, x, y, err := r.back.Size()
+	return max(rows-r.reserved, 1), cols, x, y, err
+}
+
+func (r *Renderer) Clear() error {
+	return r.back.Clear()
+}
+
+func (r *Renderer) NextLine(n int) error {
+	return r.back.NextLine(n)
+}
@@ -5,7 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"regexp"
+	"maps"
+	"math"
+	"os"
 	"slices"
 	"strings"
 	"sync"
@@ -17,84 +19,498 @@ import (
 )
 
 type Screen interface {
-	Render(ctx context.Context, tty *TTY) error
-	HandleInput(ctx context.Context, r rune) (bool, error)
-	HandleCtrl(ctx context.Context, ctrl string) (bool, error)
-	HandleMouse(ctx context.Context, code, x, y int) (bool, error)
+	Render(ctx context.Context, tty Terminal) error
+	HandleKey(ctx context.Context, key KeyEvent) (bool, error)
+	HandleMouse(ctx context.Context, mouse MouseEvent) (bool, error)
+	Resize(ctx context.Context, rows, cols int)
 	Init(ctx context.Context)
+	// Actions returns the actions the screen handles now, for the help
+	// overlay.
+	Actions() []Action
+	Status() Status
+}
+
+// Status is what the status bar shows about a screen. Fields left empty are
+// not shown.
+type Status struct {
+	// Mode is what the screen is doing, such as "choose" or "search".
+	Mode string
+	// Live is "live" or "paused" on screens that tail log groups.
+	Live string
+	// Index is the position of the current item out of Total, from 1.
+	Index int
+	Total int
+	// Buffered is the number of events held out of Capacity.
+	Buffered int
+	Capacity int
+	Err      error
+}
+
+// visibleOffset returns the offset of the first of limit rows shown out of
+// total so that index stays visible, scrolling as little as possible and
+// without leaving rows empty at the bottom.
+func visibleOffset(index, offset, limit, total int) int {
+	if limit <= 0 {
+		return max(index, 0)
+	}
+	if index < offset {
+		offset = index
+	}
+	if index >= offset+limit {
+		offset = index - limit + 1
+	}
+	return max(min(offset, total-limit), 0)
+}
+
+// hint lists the keys of actions in a screen header. Clicking it has the
+// same effect as typing the key of the first action.
+type hint struct {
+	actions []Action
+	label   string
+	from    int
+	to      int
+}
+
+// renderHints writes hints as "(keys: label, ...)" at the start of the
+// current line and returns them with the columns they were written to.
+// Hints of actions without keys are left out, and so are the hints that do
+// not fit the width of the terminal.
+func renderHints(tty Terminal, keymap *Keymap, hints []hint) []hint {
+	_, cols, _, _, err := tty.Size()
+	if err != nil {
+		cols = math.MaxInt
+	}
+	rendered := []hint{}
+	x := 2
+	parts := []string{}
+	for _, h := range hints {
+		keys := []string{}
+		for _, action := range h.actions {
+			if bound := keymap.Keys(action); len(bound) > 0 {
+				keys = append(keys, bound[0])
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%s: %s", strings.Join(keys, "/"), h.label)
+		if x+StringWidth(part) > cols {
+			break
+		}
+		h.from, h.to = x, x+StringWidth(part)-1
+		rendered = append(rendered, h)
+		parts = append(parts, part)
+		x += StringWidth(part) + 2
+	}
+	tty.WriteString("(%s)", strings.Join(parts, ", "))
+	return rendered
+}
+
+// clickedHint returns the action of the hint written at column x.
+func clickedHint(hints []hint, x int) (Action, bool) {
+	for _, h := range hints {
+		if h.from <= x && x <= h.to {
+			return h.actions[0], true
+		}
+	}
+	return "", false
 }
 
 type LoadingScreen struct {
-	start time.Time
+	start    time.Time
+	progress *LoadProgress
+	enter    func()
+	login    func([]*SSOSession)
+	redraw   func()
+	clock    clock
+	// offset is the first profile listed, of the rows that fit.
+	offset int
+	rows   int
+	theme  *Theme
+	keymap *Keymap
+	keys   keySequence
+}
+
+// clock redraws a screen that shows elapsed or remaining time when the
+// seconds it shows change. Every frame schedules the next redraw, so that the
+// clock stops once the screen is no longer drawn.
+type clock struct {
+	timer *time.Timer
+}
+
+// tick schedules redraw in d, replacing the redraw scheduled before.
+func (c *clock) tick(d time.Duration, redraw func()) {
+	if redraw == nil {
+		return
+	}
+	if c.timer == nil {
+		c.timer = time.AfterFunc(d, redraw)
+		return
+	}
+	c.timer.Reset(d)
 }
 
-func NewLoadingScreen() *LoadingScreen {
+var loadingActions = []Action{ActionUp, ActionDown, ActionApply, ActionLoadingLogin, ActionHelp, ActionCommand}
+
+func NewLoadingScreen(progress *LoadProgress, enter func()) *LoadingScreen {
 	return &LoadingScreen{
-		start: time.Now(),
+		start:    time.Now(),
+		progress: progress,
+		enter:    enter,
+		theme:    DefaultTheme,
+		keymap:   DefaultKeymap,
 	}
 }
 
 func (s *LoadingScreen) Init(ctx context.Context) {
 }
 
-func (s *LoadingScreen) Render(ctx context.Context, tty *TTY) error {
+func (s *LoadingScreen) Render(ctx context.Context, tty Terminal) error {
 	if err := tty.Clear(); err != nil {
 		return err
 	}
 
 	elapsed := time.Since(s.start)
-	switch int(elapsed.Seconds()) % 3 {
-	case 0:
-		tty.Write([]byte("Loading."))
-	case 1:
-		tty.Write([]byte("Loading.."))
-	case 2:
-		tty.Write([]byte("Loading..."))
+	s.clock.tick(time.Second-elapsed%time.Second, s.redraw)
+	dots := strings.Repeat(".", int(elapsed.Seconds())%3+1)
+	if s.progress == nil {
+		tty.WriteString("Loading%s", dots)
+		return nil
+	}
+
+	row, col, _, _, err := tty.Size()
+	if err != nil {
+		return err
+	}
+
+	tty.WriteString("%s %s (%ds)", s.theme.Title.Paint("Loading"+dots), s.progress.Summary(), int(elapsed.Seconds()))
+	tty.NextLine(1)
+	profiles := s.progress.Profiles()
+	s.rows = max(row-3, 0)
+	s.offset = min(s.offset, max(len(profiles)-s.rows, 0))
+	hints := []hint{{actions: []Action{ActionApply}, label: "continue with loaded log groups"}}
+	if len(profiles) > s.rows {
+		hints = append(hints, hint{actions: []Action{ActionUp, ActionDown}, label: "scroll"})
+	}
+	if len(s.progress.SSOSessions()) > 0 {
+		hints = append(hints, hint{actions: []Action{ActionLoadingLogin}, label: "SSO login"})
+	}
+	renderHints(tty, s.keymap, hints)
+	tty.NextLine(2)
+
+	for _, pp := range profiles[s.offset:min(s.offset+s.rows, len(profiles))] {
+		style := s.theme.Pending
+		switch pp.Status {
+		case ProfileStatusDone:
+			style = s.theme.Done
+		case ProfileStatusFailed, ProfileStatusLoginRequired:
+			style = s.theme.Failed
+		}
+		line := fmt.Sprintf("  %-30s %-12s %5d log groups", pp.Profile, pp.Status, pp.LogGroups)
+		for _, rp := range pp.Regions {
+			if rp.Err != nil {
+				line += fmt.Sprintf(" %s(!)", rp.Region)
+			} else {
+				line += fmt.Sprintf(" %s(%d)", rp.Region, rp.LogGroups)
+			}
+		}
+		if pp.Err != nil {
+			line += " " + strings.ReplaceAll(pp.Err.Error(), "\n", " ")
+		}
+		line = Truncate(line, col-3, "...")
+		tty.WriteString("%s", style.Paint(line))
+		tty.NextLine(1)
 	}
 	return nil
 }
 
-func (s *LoadingScreen) HandleInput(ctx context.Context, r rune) (bool, error) {
+func (s *LoadingScreen) HandleKey(ctx context.Context, key KeyEvent) (bool, error) {
+	action, ok := s.keys.Read(s.keymap, loadingActions, key)
+	if !ok {
+		return true, nil
+	}
+	switch action {
+	case ActionUp:
+		s.scroll(-1)
+	case ActionDown:
+		s.scroll(1)
+	case ActionApply:
+		if s.progress == nil || s.enter == nil {
+			return true, nil
+		}
+		if len(s.progress.LogGroups()) == 0 {
+			return true, nil
+		}
+		s.enter()
+	case ActionLoadingLogin:
+		if s.progress == nil || s.login == nil {
+			return true, nil
+		}
+		if sessions := s.progress.SSOSessions(); len(sessions) > 0 {
+			s.login(sessions)
+		}
+	}
 	return true, nil
 }
 
-func (s *LoadingScreen) HandleCtrl(ctx context.Context, ctrl string) (bool, error) {
+func (s *LoadingScreen) HandleMouse(ctx context.Context, mouse MouseEvent) (bool, error) {
+	switch mouse.Button {
+	case MouseWheelUp:
+		s.scroll(-1)
+	case MouseWheelDown:
+		s.scroll(1)
+	}
 	return true, nil
 }
 
-func (s *LoadingScreen) HandleMouse(ctx context.Context, code, x, y int) (bool, error) {
+// scroll moves the list of profiles by move rows.
+func (s *LoadingScreen) scroll(move int) {
+	if s.progress == nil {
+		return
+	}
+	last := max(len(s.progress.Profiles())-s.rows, 0)
+	s.offset = min(max(s.offset+move, 0), last)
+}
+
+func (s *LoadingScreen) Resize(ctx context.Context, rows, cols int) {
+}
+
+func (s *LoadingScreen) Actions() []Action {
+	return loadingActions
+}
+
+func (s *LoadingScreen) Status() Status {
+	return Status{Mode: "loading"}
+}
+
+const (
+	ssoLoginPending = iota
+	ssoLoginStarted
+	ssoLoginDone
+	ssoLoginFailed
+)
+
+type SSOLoginScreen struct {
+	mu       sync.Mutex
+	sessions []*SSOSession
+	states   []int
+	errs     []error
+	err      error
+	login    *SSOLogin
+	index    int
+	running  bool
+	done     func(*SSOSession)
+	back     func()
+	redraw   func()
+	clock    clock
+	theme    *Theme
+	keymap   *Keymap
+	keys     keySequence
+}
+
+var ssoLoginActions = []Action{ActionUp, ActionDown, ActionApply, ActionBack, ActionCancel, ActionHelp, ActionCommand}
+
+// NewSSOLoginScreen lists sessions and signs in to the chosen one. done is
+// called from the login goroutine after a successful sign in.
+func NewSSOLoginScreen(sessions []*SSOSession, done func(*SSOSession), back func()) *SSOLoginScreen {
+	return &SSOLoginScreen{
+		sessions: sessions,
+		states:   make([]int, len(sessions)),
+		errs:     make([]error, len(sessions)),
+		done:     done,
+		back:     back,
+		theme:    DefaultTheme,
+		keymap:   DefaultKeymap,
+	}
+}
+
+func (s *SSOLoginScreen) Init(ctx context.Context) {
+}
+
+func (s *SSOLoginScreen) Render(ctx context.Context, tty Terminal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := tty.Clear(); err != nil {
+		return err
+	}
+
+	_, col, _, _, err := tty.Size()
+	if err != nil {
+		return err
+	}
+
+	tty.WriteString("%s", s.theme.Title.Paint("SSO Login"))
+	tty.NextLine(1)
+	renderHints(tty, s.keymap, []hint{
+		{actions: []Action{ActionUp, ActionDown}, label: "up/down"},
+		{actions: []Action{ActionApply}, label: "login"},
+		{actions: []Action{ActionBack}, label: "back"},
+	})
+	tty.NextLine(2)
+
+	for i, session := range s.sessions {
+		name := session.Name
+		if name == "" {
+			name = session.StartURL
+		}
+		state := ""
+		switch s.states[i] {
+		case ssoLoginStarted:
+			state = s.theme.Pending.Paint("waiting for approval")
+		case ssoLoginDone:
+			state = s.theme.Done.Paint("done")
+		case ssoLoginFailed:
+			state = s.theme.Failed.Paint("failed: " + strings.ReplaceAll(s.errs[i].Error(), "\n", " "))
+		}
+		option := fmt.Sprintf("%3d. %s (%s, %d profiles)", i+1, name, session.Region, len(session.Profiles))
+		option = Truncate(option, col-3, "...")
+		if s.index == i {
+			tty.WriteString("  %s %s", s.theme.Selected.Paint(option), state)
+		} else {
+			tty.WriteString("  %s %s", option, state)
+		}
+		tty.NextLine(1)
+	}
+
+	if s.login != nil && s.running {
+		tty.NextLine(1)
+		tty.WriteString("Open the following URL and confirm the code:")
+		tty.NextLine(2)
+		tty.WriteString("  %s", s.theme.Title.Paint(s.login.VerificationURIComplete))
+		tty.NextLine(1)
+		remaining := time.Until(s.login.ExpiresAt)
+		tty.WriteString("  Code: %s (expires in %ds)", s.theme.Title.Paint(s.login.UserCode), int(remaining.Seconds()))
+		if remaining > 0 {
+			s.clock.tick(remaining%time.Second, s.redraw)
+		}
+		tty.NextLine(1)
+	}
+	return nil
+}
+
+func (s *SSOLoginScreen) HandleKey(ctx context.Context, key KeyEvent) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	action, ok := s.keys.Read(s.keymap, ssoLoginActions, key)
+	if !ok {
+		return true, nil
+	}
+	switch action {
+	case ActionBack, ActionCancel:
+		s.back()
+	case ActionDown:
+		if s.index < len(s.sessions)-1 {
+			s.index++
+		}
+	case ActionUp:
+		if s.index > 0 {
+			s.index--
+		}
+	case ActionApply:
+		if s.running || s.states[s.index] == ssoLoginDone {
+			return true, nil
+		}
+		s.running = true
+		go s.run(ctx, s.index)
+	}
 	return true, nil
 }
 
-type ChooseLogsScreen struct {
-	logs     []*LogGroup
-	selected []*LogGroup
+func (s *SSOLoginScreen) run(ctx context.Context, index int) {
+	session := s.sessions[index]
+	err := session.Login(ctx, func(login SSOLogin) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.login = &login
+		s.states[index] = ssoLoginStarted
+		s.notify()
+	})
+
+	s.mu.Lock()
+	s.running = false
+	s.login = nil
+	if err != nil {
+		s.states[index] = ssoLoginFailed
+		s.errs[index] = err
+		s.err = err
+		s.mu.Unlock()
+		s.notify()
+		return
+	}
+	s.states[index] = ssoLoginDone
+	s.mu.Unlock()
+	s.notify()
+
+	s.done(session)
+}
+
+func (s *SSOLoginScreen) notify() {
+	if s.redraw != nil {
+		s.redraw()
+	}
+}
+
+func (s *SSOLoginScreen) HandleMouse(ctx context.Context, mouse MouseEvent) (bool, error) {
+	return true, nil
+}
+
+func (s *SSOLoginScreen) Resize(ctx context.Context, rows, cols int) {
+}
+
+func (s *SSOLoginScreen) Actions() []Action {
+	return ssoLoginActions
+}
+
+func (s *SSOLoginScreen) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Status{Mode: "sso login", Index: s.index + 1, Total: len(s.sessions), Err: s.err}
+}
+
+type ProfilePickerScreen struct {
+	profiles []string
+	disabled []string
 	index    int
 	offset   int
 	limit    int
-	filter   string
-	filtered []*LogGroup
-	mode     int
-	callback func([]*LogGroup) error
+	callback func([]string)
+	hints    []hint
+	theme    *Theme
+	keymap   *Keymap
+	keys     keySequence
 	changed  bool
 }
 
-func NewChooseLogsScreen(logs []*LogGroup, selected []*LogGroup, callback func([]*LogGroup) error) *ChooseLogsScreen {
-	return &ChooseLogsScreen{
-		logs:     logs,
-		selected: selected,
-		offset:   0,
+var profilePickerActions = []Action{ActionUp, ActionDown, ActionTop, ActionBottom, ActionSelect, ActionPickerToggleAll, ActionApply, ActionHelp, ActionCommand}
+
+var profilePickerHints = []hint{
+	{actions: []Action{ActionSelect}, label: "enable/disable"},
+	{actions: []Action{ActionPickerToggleAll}, label: "all/none"},
+	{actions: []Action{ActionUp, ActionDown}, label: "up/down"},
+	{actions: []Action{ActionApply}, label: "apply"},
+}
+
+func NewProfilePickerScreen(profiles []string, disabled []string, callback func([]string)) *ProfilePickerScreen {
+	// Profiles no longer configured do not count as disabled ones.
+	disabled = slices.DeleteFunc(slices.Clone(disabled), func(p string) bool {
+		return !slices.Contains(profiles, p)
+	})
+	return &ProfilePickerScreen{
+		profiles: profiles,
+		disabled: disabled,
 		limit:    10,
-		filtered: logs,
 		callback: callback,
+		theme:    DefaultTheme,
+		keymap:   DefaultKeymap,
 		changed:  true,
 	}
 }
 
-func (s *ChooseLogsScreen) Init(ctx context.Context) {
+func (s *ProfilePickerScreen) Init(ctx context.Context) {
 }
 
-func (s *ChooseLogsScreen) Render(ctx context.Context, tty *TTY) error {
+func (s *ProfilePickerScreen) Render(ctx context.Context, tty Terminal) error {
 	if !s.changed {
 		return nil
 	}
@@ -108,164 +524,138 @@ func (s *ChooseLogsScreen) Render(ctx context.Context, tty *TTY) error {
 	if err != nil {
 		return err
 	}
-	row -= 3
-
-	s.limit = row
 
-	if len(s.filtered[s.offset:]) < s.limit {
-		s.limit = len(s.filtered[s.offset:])
+	s.limit = row - 3
+	if len(s.profiles[s.offset:]) < s.limit {
+		s.limit = len(s.profiles[s.offset:])
 	}
 
-	tty.WriteString("\x1b[1mChoose Logs\x1b[0m")
-	tty.NextLine(1)
-	if s.mode == 1 {
-		tty.WriteString("Search (enter to apply): %s", s.filter)
-		tty.NextLine(1)
-	} else if s.filter != "" && s.mode == 0 {
-		tty.WriteString("Search (r: reset): %s", s.filter)
-		tty.NextLine(1)
-	} else {
-		tty.WriteString("(/: search, space: select/unselect, j/k: up/down, h/l: prev/next, enter: apply)")
-		tty.NextLine(1)
-	}
+	tty.WriteString("%s %d/%d", s.theme.Title.Paint("Choose Profiles"), len(s.profiles)-len(s.disabled), len(s.profiles))
 	tty.NextLine(1)
+	s.hints = renderHints(tty, s.keymap, profilePickerHints)
+	tty.NextLine(2)
 
 	for i := s.offset; i < s.offset+s.limit; i++ {
-		log := s.filtered[i]
-		x := " "
-		for _, selected := range s.selected {
-			if selected.ARN() == log.ARN() {
-				x = "x"
-				break
-			}
+		x := "x"
+		if slices.Contains(s.disabled, s.profiles[i]) {
+			x = " "
 		}
-		option := fmt.Sprintf("%3d. [%s] %s (%s:%s)", i+1, x, log.Name(), log.AccountID(), log.Profile())
-		if len(option) > col-3 {
-			option = option[:col-6] + "..."
-		}
-
+		option := fmt.Sprintf("%3d. [%s] %s", i+1, x, s.profiles[i])
+		option = Truncate(option, col-3, "...")
 		if s.index == i {
-			tty.WriteString("  \x1b[7m%s\x1b[0m", option)
+			tty.WriteString("  %s", s.theme.Selected.Paint(option))
 		} else {
 			tty.WriteString("  %s", option)
 		}
 		tty.NextLine(1)
 	}
-
 	return nil
 }
 
-func (s *ChooseLogsScreen) HandleInput(ctx context.Context, r rune) (bool, error) {
-	s.changed = true
-	if s.mode == 1 {
-		switch r {
-		case 127: // Backspace
-			if len(s.filter) > 0 {
-				s.filter = s.filter[:len(s.filter)-1]
-			}
-		case 13: // Enter
-			s.mode = 0
-			s.filterLogs()
-		default:
-			if unicode.IsPrint(r) {
-				s.filter += string(r)
-			}
-		}
+func (s *ProfilePickerScreen) HandleKey(ctx context.Context, key KeyEvent) (bool, error) {
+	action, ok := s.keys.Read(s.keymap, profilePickerActions, key)
+	if !ok {
 		return true, nil
 	}
+	return s.handleAction(ctx, action)
+}
 
-	switch r {
-	case 'j':
-		s.down(ctx)
-	case 'k':
-		s.up(ctx)
-	case 'l':
-		s.next(ctx)
-	case 'h':
-		s.prev(ctx)
-	case ' ':
-		contains := false
-		for _, selected := range s.selected {
-			if selected.ARN() == s.filtered[s.index].ARN() {
-				contains = true
-				break
-			}
-		}
-		if contains {
-			s.selected = slices.DeleteFunc(s.selected, func(i *LogGroup) bool {
-				return i.ARN() == s.filtered[s.index].ARN()
-			})
+func (s *ProfilePickerScreen) handleAction(ctx context.Context, action Action) (bool, error) {
+	s.changed = true
+	switch action {
+	case ActionDown:
+		s.down()
+	case ActionUp:
+		s.up()
+	case ActionTop:
+		s.index, s.offset = 0, 0
+	case ActionBottom:
+		s.index = max(len(s.profiles)-1, 0)
+		s.offset = max(len(s.profiles)-s.limit, 0)
+	case ActionSelect:
+		s.toggle()
+	case ActionPickerToggleAll:
+		if len(s.disabled) == 0 {
+			s.disabled = slices.Clone(s.profiles)
 		} else {
-			s.selected = append(s.selected, s.filtered[s.index])
+			s.disabled = nil
 		}
-	case '/': // Filter Input Mode
-		s.mode = 1
-		s.filter = ""
-	case 13: // Enter
-		if len(s.selected) == 0 {
+	case ActionApply:
+		if len(s.disabled) == len(s.profiles) {
 			return true, nil
 		}
-		if err := s.callback(s.selected); err != nil {
-			return false, err
-		}
-	case 'r': // Reset Filter
-		s.filter = ""
-		s.filterLogs()
+		s.callback(s.disabled)
 	}
 	return true, nil
 }
 
-func (s *ChooseLogsScreen) HandleCtrl(ctx context.Context, ctrl string) (bool, error) {
-	s.changed = true
-	switch ctrl {
-	case "\x1b[A":
-		s.up(ctx)
-	case "\x1b[B":
-		s.down(ctx)
+func (s *ProfilePickerScreen) HandleMouse(ctx context.Context, mouse MouseEvent) (bool, error) {
+	if mouse.Release || mouse.Motion {
+		return true, nil
 	}
-	return true, nil
-}
-
-func (s *ChooseLogsScreen) HandleMouse(ctx context.Context, code, x, y int) (bool, error) {
-	switch code {
-	case 0:
-		if y < 3 {
+	switch mouse.Button {
+	case MouseWheelUp:
+		if s.index > 0 {
+			s.changed = true
+			s.up()
+		}
+	case MouseWheelDown:
+		if s.index < len(s.profiles)-1 {
+			s.changed = true
+			s.down()
+		}
+	case MouseLeft:
+		if mouse.Y == 2 {
+			if action, ok := clickedHint(s.hints, mouse.X); ok {
+				return s.handleAction(ctx, action)
+			}
+			return true, nil
+		}
+		clickidx := s.offset + mouse.Y - 4
+		if clickidx < 0 || clickidx >= len(s.profiles) {
 			return true, nil
 		}
 		s.changed = true
-		clickidx := s.offset + y - 4
 		eq := clickidx == s.index
-		s.index = s.offset + y - 4
-		if s.index < 0 {
-			s.index = 0
-		}
+		s.index = clickidx
 		if eq {
-			return s.HandleInput(ctx, ' ')
+			s.toggle()
 		}
 	}
 	return true, nil
 }
 
-func (s *ChooseLogsScreen) filterLogs() {
-	if s.filter == "" {
-		s.filtered = s.logs
-		s.index = 0
-		s.offset = 0
+func (s *ProfilePickerScreen) Resize(ctx context.Context, rows, cols int) {
+	s.limit = min(rows-3, len(s.profiles))
+	s.offset = visibleOffset(s.index, s.offset, s.limit, len(s.profiles))
+	s.changed = true
+}
+
+func (s *ProfilePickerScreen) Actions() []Action {
+	return profilePickerActions
+}
+
+func (s *ProfilePickerScreen) Status() Status {
+	return Status{Mode: "profiles", Index: s.index + 1, Total: len(s.profiles)}
+}
+
+func (s *ProfilePickerScreen) toggle() {
+	if len(s.profiles) == 0 {
 		return
 	}
-	s.filtered = []*LogGroup{}
-	for _, log := range s.logs {
-		if strings.Contains(log.ARN(), s.filter) {
-			s.filtered = append(s.filtered, log)
-		}
+	profile := s.profiles[s.index]
+	if slices.Contains(s.disabled, profile) {
+		s.disabled = slices.DeleteFunc(s.disabled, func(p string) bool {
+			return p == profile
+		})
+	} else {
+		s.disabled = append(s.disabled, profile)
 	}
-	s.index = 0
-	s.offset = 0
 }
 
-func (s *ChooseLogsScreen) down(_ context.Context) {
+func (s *ProfilePickerScreen) down() {
 	s.index++
-	if s.index >= len(s.filtered) {
+	if s.index >= len(s.profiles) {
 		s.index = 0
 		s.offset = 0
 		return
@@ -275,10 +665,10 @@ func (s *ChooseLogsScreen) down(_ context.Context) {
 	}
 }
 
-func (s *ChooseLogsScreen) up(_ context.Context) {
+func (s *ProfilePickerScreen) up() {
 	if s.index == 0 {
-		s.index = len(s.filtered) - 1
-		s.offset = len(s.filtered) - s.limit
+		s.index = len(s.profiles) - 1
+		s.offset = max(len(s.profiles)-s.limit, 0)
 		return
 	}
 	s.index--
@@ -287,17 +677,394 @@ func (s *ChooseLogsScreen) up(_ context.Context) {
 	}
 }
 
-func (s *ChooseLogsScreen) next(_ context.Context) {
-	nextOffset := s.offset + s.limit + 1
-	if len(s.filtered)-1 <= nextOffset {
-		return
-	}
-	s.offset = nextOffset
-	s.index = nextOffset
-}
-
-func (s *ChooseLogsScreen) prev(_ context.Context) {
-	prevOffset := s.offset - s.limit
+type ChooseLogsScreen struct {
+	logs     []*LogGroup
+	selected []*LogGroup
+	index    int
+	offset   int
+	limit    int
+	filter   string
+	filtered []*LogGroup
+	mode     int
+	callback func([]*LogGroup) error
+	refresh  func()
+	status   string
+
+	pickProfiles func()
+	login        func()
+	hints        []hint
+	theme        *Theme
+	keymap       *Keymap
+	keys         keySequence
+	changed      bool
+}
+
+var chooseLogsActions = []Action{
+	ActionUp, ActionDown, ActionTop, ActionBottom, ActionChoosePageUp, ActionChoosePageDown,
+	ActionSelect, ActionChooseSearch, ActionApply, ActionChooseResetFilter,
+	ActionChooseRefresh, ActionChooseProfiles, ActionChooseLogin, ActionHelp, ActionCommand,
+}
+
+var chooseLogsHints = []hint{
+	{actions: []Action{ActionChooseSearch}, label: "search"},
+	{actions: []Action{ActionSelect}, label: "select/unselect"},
+	{actions: []Action{ActionUp, ActionDown}, label: "up/down"},
+	{actions: []Action{ActionChoosePageUp, ActionChoosePageDown}, label: "prev/next"},
+	{actions: []Action{ActionChooseRefresh}, label: "refresh"},
+	{actions: []Action{ActionChooseProfiles}, label: "profiles"},
+	{actions: []Action{ActionApply}, label: "apply"},
+}
+
+func NewChooseLogsScreen(logs []*LogGroup, selected []*LogGroup, callback func([]*LogGroup) error) *ChooseLogsScreen {
+	return &ChooseLogsScreen{
+		logs:     logs,
+		selected: selected,
+		offset:   0,
+		limit:    10,
+		filtered: logs,
+		callback: callback,
+		theme:    DefaultTheme,
+		keymap:   DefaultKeymap,
+		changed:  true,
+	}
+}
+
+func (s *ChooseLogsScreen) Init(ctx context.Context) {
+}
+
+// SetLogs replaces the log groups while keeping the filter, the selection and
+// the cursor position where possible.
+func (s *ChooseLogsScreen) SetLogs(logs []*LogGroup) {
+	index, offset := s.index, s.offset
+	s.logs = logs
+	s.filterLogs()
+	if index < len(s.filtered) {
+		s.index = index
+		s.offset = offset
+	}
+	s.changed = true
+}
+
+func (s *ChooseLogsScreen) SetStatus(status string) {
+	s.status = status
+	s.changed = true
+}
+
+func (s *ChooseLogsScreen) Render(ctx context.Context, tty Terminal) error {
+	if !s.changed {
+		return nil
+	}
+	s.changed = false
+
+	if err := tty.Clear(); err != nil {
+		return err
+	}
+	s.hints = nil
+
+	row, col, _, _, err := tty.Size()
+	if err != nil {
+		return err
+	}
+	row -= 3
+
+	s.limit = row
+
+	if len(s.filtered[s.offset:]) < s.limit {
+		s.limit = len(s.filtered[s.offset:])
+	}
+
+	tty.WriteString("%s", s.theme.Title.Paint("Choose Logs"))
+	if s.status != "" {
+		tty.WriteString(" %s", s.status)
+	}
+	tty.NextLine(1)
+	if s.mode == 1 {
+		tty.WriteString("Search (enter to apply): %s", s.filter)
+		tty.NextLine(1)
+	} else if s.filter != "" && s.mode == 0 {
+		renderHints(tty, s.keymap, []hint{{actions: []Action{ActionChooseResetFilter}, label: "reset"}})
+		tty.WriteString(" Search: %s", s.filter)
+		tty.NextLine(1)
+	} else {
+		s.hints = renderHints(tty, s.keymap, chooseLogsHints)
+		tty.NextLine(1)
+	}
+	tty.NextLine(1)
+
+	for i := s.offset; i < s.offset+s.limit; i++ {
+		log := s.filtered[i]
+		x := " "
+		for _, selected := range s.selected {
+			if selected.ARN() == log.ARN() {
+				x = "x"
+				break
+			}
+		}
+		via := log.Profile()
+		if log.Linked() {
+			via += " linked"
+		}
+		option := fmt.Sprintf("%3d. [%s] %s (%s:%s:%s)", i+1, x, log.Name(), log.AccountID(), log.Region(), via)
+		option = Truncate(option, col-3, "...")
+
+		if s.index == i {
+			tty.WriteString("  %s", s.theme.Selected.Paint(option))
+		} else {
+			tty.WriteString("  %s", option)
+		}
+		tty.NextLine(1)
+	}
+
+	return nil
+}
+
+func (s *ChooseLogsScreen) HandleKey(ctx context.Context, key KeyEvent) (bool, error) {
+	s.changed = true
+	if s.mode == 1 {
+		switch {
+		case key.Key == KeyBackspace:
+			if filter := []rune(s.filter); len(filter) > 0 {
+				s.filter = string(filter[:len(filter)-1])
+			}
+		case key.Key == KeyEnter:
+			s.mode = 0
+			s.filterLogs()
+		case key.Key == KeyEscape:
+			s.mode = 0
+			s.filter = ""
+			s.filterLogs()
+		case key.Key == KeyPaste:
+			s.filter += strings.Map(func(r rune) rune {
+				if unicode.IsPrint(r) {
+					return r
+				}
+				return -1
+			}, key.Text)
+		case key.Key == KeyRune && key.Mod&(ModCtrl|ModAlt) == 0:
+			if unicode.IsPrint(key.Rune) {
+				s.filter += string(key.Rune)
+			}
+		}
+		return true, nil
+	}
+
+	action, ok := s.keys.Read(s.keymap, chooseLogsActions, key)
+	if !ok {
+		return true, nil
+	}
+	return s.handleAction(ctx, action)
+}
+
+func (s *ChooseLogsScreen) handleAction(ctx context.Context, action Action) (bool, error) {
+	s.changed = true
+	switch action {
+	case ActionDown:
+		s.down(ctx)
+	case ActionUp:
+		s.up(ctx)
+	case ActionChoosePageDown:
+		s.next(ctx)
+	case ActionChoosePageUp:
+		s.prev(ctx)
+	case ActionTop:
+		s.index, s.offset = 0, 0
+	case ActionBottom:
+		s.index = max(len(s.filtered)-1, 0)
+		s.offset = max(len(s.filtered)-s.limit, 0)
+	case ActionSelect:
+		if len(s.filtered) == 0 {
+			return true, nil
+		}
+		contains := false
+		for _, selected := range s.selected {
+			if selected.ARN() == s.filtered[s.index].ARN() {
+				contains = true
+				break
+			}
+		}
+		if contains {
+			s.selected = slices.DeleteFunc(s.selected, func(i *LogGroup) bool {
+				return i.ARN() == s.filtered[s.index].ARN()
+			})
+		} else {
+			s.selected = append(s.selected, s.filtered[s.index])
+		}
+	case ActionChooseSearch:
+		s.mode = 1
+		s.filter = ""
+	case ActionApply:
+		if len(s.selected) == 0 {
+			return true, nil
+		}
+		if err := s.callback(s.selected); err != nil {
+			return false, err
+		}
+	case ActionChooseResetFilter:
+		s.filter = ""
+		s.filterLogs()
+	case ActionChooseRefresh:
+		if s.refresh != nil {
+			s.refresh()
+		}
+	case ActionChooseProfiles:
+		if s.pickProfiles != nil {
+			s.pickProfiles()
+		}
+	case ActionChooseLogin:
+		if s.login != nil {
+			s.login()
+		}
+	}
+	return true, nil
+}
+
+func (s *ChooseLogsScreen) HandleMouse(ctx context.Context, mouse MouseEvent) (bool, error) {
+	if mouse.Release || mouse.Motion {
+		return true, nil
+	}
+	switch mouse.Button {
+	case MouseWheelUp:
+		if s.index > 0 {
+			s.changed = true
+			s.up(ctx)
+		}
+	case MouseWheelDown:
+		if s.index < len(s.filtered)-1 {
+			s.changed = true
+			s.down(ctx)
+		}
+	case MouseLeft:
+		if mouse.Y == 2 {
+			if action, ok := clickedHint(s.hints, mouse.X); ok {
+				return s.handleAction(ctx, action)
+			}
+			return true, nil
+		}
+		if mouse.Y < 3 {
+			return true, nil
+		}
+		clickidx := s.offset + mouse.Y - 4
+		if clickidx < 0 || clickidx >= len(s.filtered) {
+			return true, nil
+		}
+		s.changed = true
+		eq := clickidx == s.index
+		s.index = clickidx
+		if eq {
+			return s.handleAction(ctx, ActionSelect)
+		}
+	}
+	return true, nil
+}
+
+func (s *ChooseLogsScreen) Resize(ctx context.Context, rows, cols int) {
+	s.limit = min(rows-3, len(s.filtered))
+	s.offset = visibleOffset(s.index, s.offset, s.limit, len(s.filtered))
+	s.changed = true
+}
+
+// Actions returns no actions while a search is typed, so that every key goes
+// to the search.
+func (s *ChooseLogsScreen) Actions() []Action {
+	if s.mode == 1 {
+		return nil
+	}
+	return chooseLogsActions
+}
+
+func (s *ChooseLogsScreen) Status() Status {
+	status := Status{Mode: "choose", Total: len(s.filtered)}
+	if s.mode == 1 {
+		status.Mode = "search"
+	}
+	if len(s.filtered) > 0 {
+		status.Index = s.index + 1
+	}
+	return status
+}
+
+// SetFilter shows only the log groups whose ARN contains filter.
+func (s *ChooseLogsScreen) SetFilter(filter string) {
+	s.mode = 0
+	s.filter = filter
+	s.filterLogs()
+	s.changed = true
+}
+
+// Jump moves the cursor to the log group named name, resetting the filter if
+// it hides the group.
+func (s *ChooseLogsScreen) Jump(name string) error {
+	find := func() int {
+		return slices.IndexFunc(s.filtered, func(log *LogGroup) bool {
+			return log.Name() == name || log.ARN() == name
+		})
+	}
+	idx := find()
+	if idx < 0 && s.filter != "" {
+		s.SetFilter("")
+		idx = find()
+	}
+	if idx < 0 {
+		return fmt.Errorf("no such log group: %s", name)
+	}
+	s.index = idx
+	s.offset = visibleOffset(idx, s.offset, s.limit, len(s.filtered))
+	s.changed = true
+	return nil
+}
+
+func (s *ChooseLogsScreen) filterLogs() {
+	if s.filter == "" {
+		s.filtered = s.logs
+		s.index = 0
+		s.offset = 0
+		return
+	}
+	s.filtered = []*LogGroup{}
+	for _, log := range s.logs {
+		if strings.Contains(log.ARN(), s.filter) {
+			s.filtered = append(s.filtered, log)
+		}
+	}
+	s.index = 0
+	s.offset = 0
+}
+
+func (s *ChooseLogsScreen) down(_ context.Context) {
+	s.index++
+	if s.index >= len(s.filtered) {
+		s.index = 0
+		s.offset = 0
+		return
+	}
+	if s.index >= s.offset+s.limit {
+		s.offset++
+	}
+}
+
+func (s *ChooseLogsScreen) up(_ context.Context) {
+	if s.index == 0 {
+		s.index = len(s.filtered) - 1
+		s.offset = len(s.filtered) - s.limit
+		return
+	}
+	s.index--
+	if s.index < s.offset {
+		s.offset--
+	}
+}
+
+func (s *ChooseLogsScreen) next(_ context.Context) {
+	nextOffset := s.offset + s.limit + 1
+	if len(s.filtered)-1 <= nextOffset {
+		return
+	}
+	s.offset = nextOffset
+	s.index = nextOffset
+}
+
+func (s *ChooseLogsScreen) prev(_ context.Context) {
+	prevOffset := s.offset - s.limit
 	if prevOffset < 0 {
 		prevOffset = 0
 	}
@@ -320,9 +1087,111 @@ type DisplayLogScreen struct {
 	live    map[string]bool
 	changed map[string]bool
 	view    map[string]int
+	errs    map[string]error
 	row     int
 	col     int
 	rw      sync.RWMutex
+
+	selection map[string]eventRange
+	dragging  bool
+	tabs      [][2]int
+	redraw    func()
+	// wrap shows long messages on as many rows as they need instead of
+	// truncating them, and lines maps each rendered row to its event.
+	wrap   bool
+	lines  map[string][]int
+	theme  *Theme
+	keymap *Keymap
+	keys   keySequence
+	// patterns are the filter patterns of the live tails, and location the
+	// time zone timestamps are shown in, if not their own.
+	patterns map[string]string
+	location *time.Location
+	stops    map[string]context.CancelFunc
+	// panes are the groups tiled by the split layouts, and weights their
+	// share of the screen. zoom shows the focused pane alone.
+	split   int
+	panes   []*LogGroup
+	weights map[string]int
+	zoom    bool
+	// chart shows the histogram of each pane above its events, and
+	// histograms are the ones last drawn.
+	chart      bool
+	histograms map[string]histogram
+	// bookmarks are the marked events of every group in time order, and
+	// panel lists them beside the panes from panelTop.
+	bookmarks []*bookmark
+	panel     bool
+	panelTop  int
+	// follow opens the timeline of a correlation ID found by correlator,
+	// given with its events, or lets the user choose among several IDs.
+	correlator *Correlator
+	follow     func(ids []string, events []timelineEvent)
+	// grouped shows the invocations of Lambda groups, as lambdas groups
+	// them, instead of their events, with the expanded ones listing their
+	// events.
+	grouped         map[string]bool
+	lambdas         map[string]*lambdaIndex
+	expanded        map[string]bool
+	invocationIndex map[string]int
+	invocationTop   map[string]int
+	// fold folds continuation lines into the events they continue, as
+	// multiline finds them.
+	fold      bool
+	multiline *Multiline
+}
+
+const (
+	splitNone    = 0
+	splitColumns = 1
+	splitRows    = 2
+
+	// maxPanes is the most groups tiled at once.
+	maxPanes = 4
+	// Pane weights start at defaultWeight and are resized within
+	// [1, maxWeight].
+	defaultWeight = 4
+	maxWeight     = 16
+
+	// Histograms are chartRows high, and drawn in panes of at least
+	// minChartPaneRows rows. Sparklines have sparklineBuckets buckets.
+	chartRows        = 3
+	minChartPaneRows = 10
+	sparklineBuckets = 6
+	// scaleWidth is the width of the scale beside histograms.
+	scaleWidth = 10
+)
+
+// pane is where a group is drawn, in cells from the top left of the screen.
+type pane struct {
+	log       *LogGroup
+	top, left int
+	rows      int
+	cols      int
+}
+
+var displayLogActions = []Action{
+	ActionBack, ActionUp, ActionDown, ActionTop, ActionBottom, ActionDisplayPageUp, ActionDisplayPageDown,
+	ActionDisplayNextGroup, ActionDisplayPrevGroup, ActionDisplayWrap, ActionDisplayToggleLive,
+	ActionDisplayDetail, ActionCancel, ActionDisplaySplit, ActionDisplayFocusNext, ActionDisplayFocusPrev,
+	ActionDisplayGrow, ActionDisplayShrink, ActionDisplayZoom, ActionDisplayHistogram,
+	ActionDisplayPrevBucket, ActionDisplayNextBucket, ActionDisplayBookmark, ActionDisplayBookmarks,
+	ActionDisplayNextBookmark, ActionDisplayPrevBookmark, ActionDisplayFollow, ActionDisplayInvocations,
+	ActionHelp, ActionCommand,
+}
+
+const (
+	timestampLayout = "2006-01-02 15:04:05"
+)
+
+// eventRange is a range of event indexes from where a drag started to where
+// it is now, in either order.
+type eventRange struct {
+	from, to int
+}
+
+func (r eventRange) bounds() (int, int) {
+	return min(r.from, r.to), max(r.from, r.to)
 }
 
 func NewDisplayLogScreen(logs []*LogGroup, back func([]*LogGroup)) *DisplayLogScreen {
@@ -337,6 +1206,34 @@ func NewDisplayLogScreen(logs []*LogGroup, back func([]*LogGroup)) *DisplayLogSc
 		live:    make(map[string]bool, len(logs)),
 		changed: make(map[string]bool, len(logs)),
 		view:    make(map[string]int, len(logs)),
+		errs:    make(map[string]error, len(logs)),
+
+		selection: make(map[string]eventRange, len(logs)),
+		lines:     make(map[string][]int, len(logs)),
+		theme:     DefaultTheme,
+		keymap:    DefaultKeymap,
+		patterns:  make(map[string]string, len(logs)),
+		stops:     make(map[string]context.CancelFunc, len(logs)),
+		panes:     slices.Clone(logs[:min(len(logs), maxPanes)]),
+		weights:   make(map[string]int, len(logs)),
+
+		chart:      true,
+		histograms: make(map[string]histogram, len(logs)),
+		correlator: DefaultCorrelator,
+
+		grouped:         make(map[string]bool, len(logs)),
+		expanded:        make(map[string]bool),
+		invocationIndex: make(map[string]int, len(logs)),
+		invocationTop:   make(map[string]int, len(logs)),
+		lambdas:         make(map[string]*lambdaIndex, len(logs)),
+
+		fold:      true,
+		multiline: DefaultMultiline,
+	}
+	for _, log := range logs {
+		if isLambda(log) {
+			screen.lambdas[log.ARN()] = newLambdaIndex(nil)
+		}
 	}
 
 	return screen
@@ -344,262 +1241,1120 @@ func NewDisplayLogScreen(logs []*LogGroup, back func([]*LogGroup)) *DisplayLogSc
 
 func (s *DisplayLogScreen) Init(ctx context.Context) {
 	for _, log := range s.logs {
-		go func(ctx context.Context, log *LogGroup) {
-			ctx, cancel := context.WithCancel(ctx)
-			defer cancel()
+		s.start(ctx, log)
+	}
+}
+
+// start tails log with its filter pattern, stopping the tail started before.
+func (s *DisplayLogScreen) start(ctx context.Context, log *LogGroup) {
+	ctx, stop := context.WithCancel(ctx)
+	s.rw.Lock()
+	if prev, ok := s.stops[log.ARN()]; ok {
+		prev()
+	}
+	s.stops[log.ARN()] = stop
+	if stream, ok := s.streams[log.ARN()]; ok {
+		stream.Close()
+		delete(s.streams, log.ARN())
+	}
+	s.rw.Unlock()
+
+	go func(ctx context.Context, log *LogGroup) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		s.rw.Lock()
+		if ctx.Err() != nil {
+			s.rw.Unlock()
+			return
+		}
+		s.buffers[log.ARN()] = []*LogEvent{}
+		s.indexInvocations(log.ARN())
+		stream, err := log.Stream(ctx, s.patterns[log.ARN()])
+		if err != nil {
+			s.errs[log.ARN()] = err
+			s.changed[log.ARN()] = true
+			s.rw.Unlock()
+			if s.redraw != nil {
+				s.redraw()
+			}
+			return
+		}
+		s.streams[log.ARN()] = stream
+		s.index[log.ARN()] = -1
+		s.offset[log.ARN()] = 0
+		s.live[log.ARN()] = true
+		s.changed[log.ARN()] = true
+		s.rw.Unlock()
+		if s.redraw != nil {
+			s.redraw()
+		}
+		defer func(stream *cloudwatchlogs.StartLiveTailEventStream) {
+			if err := recover(); err != nil {
+				cancel()
+			}
+			stream.Close()
+		}(stream)
+		for {
+			var evt interface{}
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-stream.Events():
+				if !ok {
+					if err := stream.Err(); err != nil {
+						s.rw.Lock()
+						s.errs[log.ARN()] = err
+						s.changed[log.ARN()] = true
+						s.rw.Unlock()
+						if s.redraw != nil {
+							s.redraw()
+						}
+					}
+					return
+				}
+				evt = e
+			}
+			u, ok := evt.(*types.StartLiveTailResponseStreamMemberSessionUpdate)
+			if !ok {
+				continue
+			}
+			if len(u.Value.SessionResults) == 0 {
+				continue
+			}
+
 			s.rw.Lock()
-			s.buffers[log.ARN()] = []*LogEvent{}
-			stream, err := log.Stream(ctx)
-			if err != nil {
+			if ctx.Err() != nil {
+				// Stopped by a restart while waiting for the lock.
+				s.rw.Unlock()
 				return
 			}
-			s.streams[log.ARN()] = stream
-			s.index[log.ARN()] = -1
-			s.offset[log.ARN()] = 0
-			s.live[log.ARN()] = true
 			s.changed[log.ARN()] = true
+			for _, evt := range u.Value.SessionResults {
+				s.appendEvent(log.ARN(), NewLogEvent(evt))
+			}
+			s.trimBuffer(log.ARN())
 			s.rw.Unlock()
-			defer func(stream *cloudwatchlogs.StartLiveTailEventStream) {
-				if err := recover(); err != nil {
-					cancel()
-				}
-				stream.Close()
-			}(stream)
-			for {
-				var evt interface{}
-				select {
-				case <-ctx.Done():
-					return
-				case evt = <-stream.Events():
-				}
-				u, ok := evt.(*types.StartLiveTailResponseStreamMemberSessionUpdate)
-				if !ok {
-					continue
-				}
-				if len(u.Value.SessionResults) == 0 {
-					continue
-				}
+			if s.redraw != nil {
+				s.redraw()
+			}
+		}
+	}(ctx, log)
+}
 
-				s.rw.Lock()
-				s.changed[log.ARN()] = true
-				for _, evt := range u.Value.SessionResults {
-					s.buffers[log.ARN()] = append(s.buffers[log.ARN()], NewLogEvent(evt))
-				}
-				if len(s.buffers[log.ARN()]) > MaxEvents {
-					s.buffers[log.ARN()] = s.buffers[log.ARN()][len(s.buffers[log.ARN()])-MaxEvents:]
+func (s *DisplayLogScreen) Render(ctx context.Context, tty Terminal) error {
+	row, col, _, _, err := tty.Size()
+	if err != nil {
+		return err
+	}
+
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+
+	s.row = row
+	s.col = col
+	panes := s.layout()
+	changed := false
+	for _, p := range panes {
+		changed = changed || s.changed[p.log.ARN()]
+		s.changed[p.log.ARN()] = false
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := tty.Clear(); err != nil {
+		return err
+	}
+	s.handleViewMode(ctx, tty)
+
+	panel := s.panelWidth()
+	if len(panes) == 1 && panel == 0 {
+		return s.renderPane(tty, panes[0])
+	}
+	for i, p := range panes {
+		vt := NewVirtualTerminal(p.rows, p.cols)
+		if err := s.renderPane(vt, p); err != nil {
+			return err
+		}
+		for r := 0; r < p.rows; r++ {
+			tty.WriteString(CursorMove+"%s", p.top+r+1, p.left+1, vt.Line(r))
+			if s.split == splitColumns && i > 0 {
+				tty.WriteString(CursorMove+"%s", p.top+r+1, p.left, s.theme.Header.Paint("│"))
+			}
+		}
+	}
+	if panel > 0 {
+		vt := NewVirtualTerminal(row, panel-1)
+		s.renderPanel(vt, row, panel-1)
+		for r := 0; r < row; r++ {
+			tty.WriteString(CursorMove+"%s%s", r+1, col-panel+1, s.theme.Header.Paint("│"), vt.Line(r))
+		}
+	}
+	return nil
+}
+
+// layout returns the panes on the screen: the focused group alone, or the
+// split panes sized by their weights.
+func (s *DisplayLogScreen) layout() []pane {
+	cols := s.col - s.panelWidth()
+	if s.split == splitNone || s.zoom || len(s.panes) < 2 {
+		return []pane{{log: s.log, rows: s.row, cols: cols}}
+	}
+	size := cols - (len(s.panes) - 1)
+	if s.split == splitRows {
+		size = s.row
+	}
+	total := 0
+	for _, log := range s.panes {
+		total += s.weight(log)
+	}
+	panes := make([]pane, len(s.panes))
+	at := 0
+	for i, log := range s.panes {
+		n := max(size*s.weight(log)/total, 1)
+		if i == len(s.panes)-1 {
+			n = max(size-at, 1)
+		}
+		if s.split == splitRows {
+			panes[i] = pane{log: log, top: at, rows: n, cols: cols}
+		} else {
+			panes[i] = pane{log: log, left: at, rows: s.row, cols: n}
+			at++ // the divider
+		}
+		at += n
+	}
+	return panes
+}
+
+func (s *DisplayLogScreen) weight(log *LogGroup) int {
+	if w, ok := s.weights[log.ARN()]; ok {
+		return w
+	}
+	return defaultWeight
+}
+
+// paneRows returns the rows of the pane showing arn, header included.
+func (s *DisplayLogScreen) paneRows(arn string) int {
+	for _, p := range s.layout() {
+		if p.log.ARN() == arn {
+			return p.rows
+		}
+	}
+	return s.row
+}
+
+// chartHeight returns the rows of the histogram in the pane showing arn.
+func (s *DisplayLogScreen) chartHeight(arn string) int {
+	if !s.chart || s.view[arn] == viewModeAlt || s.grouped[arn] || s.paneRows(arn) < minChartPaneRows {
+		return 0
+	}
+	return chartRows
+}
+
+// listRows returns the rows of the pane showing arn that list events.
+func (s *DisplayLogScreen) listRows(arn string) int {
+	return s.paneRows(arn) - 1 - s.chartHeight(arn)
+}
+
+// paneAt returns the pane at 1-based column x and row y.
+func (s *DisplayLogScreen) paneAt(x, y int) (pane, bool) {
+	for _, p := range s.layout() {
+		if p.top < y && y <= p.top+p.rows && p.left < x && x <= p.left+p.cols {
+			return p, true
+		}
+	}
+	return pane{}, false
+}
+
+// renderPane draws p, which takes the whole of tty. A pane alone lists the
+// groups in its header, and a split pane only its own.
+func (s *DisplayLogScreen) renderPane(tty Terminal, p pane) error {
+	row, col := p.rows, p.cols
+	arn := p.log.ARN()
+	live := s.live[arn]
+	split := s.split != splitNone && !s.zoom && len(s.panes) > 1
+
+	buf := bytes.NewBuffer(nil)
+
+	switch {
+	case split:
+		style := s.theme.Header
+		if p.log == s.log {
+			style = style.Merge(s.theme.Selected)
+		}
+		spark := s.sparkline(arn)
+		buf.WriteString(style.Paint(Truncate(" "+p.log.Name()+" ", col-9-sparklineBuckets, "... ")))
+		buf.WriteString(spark + " ")
+	case len(s.logs) > 1:
+		s.tabs = s.tabs[:0]
+		x := 1
+		for _, log := range s.logs {
+			name := " " + log.Name() + " "
+			width := StringWidth(name) + sparklineBuckets + 1
+			if x+width > col-8 {
+				break
+			}
+			s.tabs = append(s.tabs, [2]int{x, x + width - 1})
+			if log == s.log {
+				buf.WriteString(s.theme.Header.Merge(s.theme.Selected).Paint(name))
+			} else {
+				buf.WriteString(name)
+			}
+			buf.WriteString(s.sparkline(log.ARN()) + " ")
+			x += width
+		}
+	default:
+		buf.WriteString(s.theme.Header.Paint(Truncate(arn, col-8, "...")))
+	}
+	buf.WriteString(" ")
+	status := "paused"
+	if live {
+		status = "live"
+	}
+	if s.wrap {
+		status += " wrap"
+	}
+	if pattern := s.patterns[arn]; pattern != "" {
+		status += " filter:" + pattern
+	}
+	if x := s.lambdas[arn]; x != nil {
+		if summary := x.summary.String(); summary != "" {
+			status += " | " + summary
+		}
+	}
+	buf.WriteString(s.theme.Header.Paint(status))
+	buf.WriteString("\n")
+
+	chart := s.chartHeight(arn)
+	if chart > 0 && len(s.buffers[arn]) > 0 {
+		h := s.histogram(arn, col)
+		scale := fmt.Sprintf("%*s", scaleWidth-1, h.scale())
+		for i, line := range h.chart(chart, s.theme) {
+			buf.WriteString(line + " ")
+			if i == 0 {
+				buf.WriteString(s.theme.Header.Paint(scale))
+			}
+			buf.WriteString("\n")
+		}
+		s.histograms[arn] = h
+	}
+
+	if len(s.buffers[arn]) == 0 {
+		body := strings.ReplaceAll(buf.String(), "\n", CursorNextLine)
+		tty.WriteString("%s", body)
+		return nil
+	}
+
+	if s.grouped[arn] {
+		s.renderInvocations(buf, arn, row-1, col)
+		tty.WriteString("%s", strings.ReplaceAll(buf.String(), "\n", CursorNextLine))
+		return nil
+	}
+
+	view := s.view[arn]
+	if view == viewModeAlt {
+		if split {
+			body := strings.ReplaceAll(buf.String(), "\n", CursorNextLine)
+			tty.WriteString("%s", body)
+		}
+
+		from, to := s.selectedRange(arn)
+		for idx := from; idx <= to; idx++ {
+			log := s.buffers[arn][idx]
+
+			message := log.Message()
+			if len(log.folded) > 0 {
+				message = log.Detail()
+			}
+
+			var b []byte
+			// try json.Unmarshal
+			var jsonData map[string]interface{}
+			if err := json.Unmarshal([]byte(message), &jsonData); err == nil {
+				b, _ = json.MarshalIndent(jsonData, "", "  ")
+			} else {
+				b = []byte(message)
+			}
+
+			if from != to {
+				tty.WriteString("%s", s.theme.Timestamp.Paint(s.timestamp(log)))
+				tty.NextLine(1)
+			}
+			if i := s.bookmarkIndex(log); i >= 0 && s.bookmarks[i].note != "" {
+				tty.WriteString("%s", s.theme.Warn.Paint("* "+s.bookmarks[i].note))
+				tty.NextLine(1)
+			}
+			body := strings.ReplaceAll(string(b), "\n", CursorNextLine)
+			tty.WriteString("%s", body)
+			tty.NextLine(1)
+		}
+
+		return nil
+	}
+
+	rows := row - 2 - chart
+
+	allEvents := s.buffers[arn]
+	lastidx := len(allEvents) - 1
+
+	if live {
+		offset := lastidx - rows
+		if offset < 0 {
+			offset = 0
+		}
+		s.offset[arn] = offset
+		s.index[arn] = lastidx
+	}
+
+	width := col - len(timestampLayout) - 1
+	if s.wrap {
+		s.scrollWrapped(arn, width, rows+1)
+	}
+
+	idx := s.index[arn]
+	offset := s.offset[arn]
+	lines := []int{}
+	for evtidx := offset; evtidx <= lastidx && len(lines) <= rows; evtidx++ {
+		evt := allEvents[evtidx]
+		timestamp := s.timestamp(evt)
+		message := printable(evt.Message())
+		more := ""
+		if n := len(evt.folded); n > 0 {
+			more = fmt.Sprintf(" [+%d lines]", n)
+		}
+		parts := []string{Truncate(strings.ReplaceAll(message, "\n", " "), width-len(more), "...")}
+		if s.wrap {
+			parts = Wrap(message, width-len(more))
+		}
+
+		for i, part := range parts {
+			if len(lines) > rows {
+				break
+			}
+			tsStyle, msgStyle := s.theme.Timestamp, s.theme.Message
+			if evtidx == idx || s.inSelection(arn, evtidx) {
+				tsStyle = tsStyle.Merge(s.theme.Selected)
+				msgStyle = msgStyle.Merge(s.theme.Selected)
+			}
+			line := ""
+			if i == 0 && s.bookmarkIndex(evt) >= 0 {
+				line += tsStyle.Paint(timestamp) + tsStyle.Merge(s.theme.Warn).Paint("*")
+			} else if i == 0 {
+				line += tsStyle.Paint(timestamp + " ")
+			} else {
+				line += tsStyle.Paint(strings.Repeat(" ", len(timestamp)+1))
+			}
+			line += s.theme.Highlight(part, msgStyle)
+			if i == 0 && more != "" {
+				line += s.theme.Pending.Paint(more)
+			}
+			buf.WriteString(line)
+			buf.WriteString("\n")
+			lines = append(lines, evtidx)
+		}
+	}
+
+	s.lines[arn] = lines
+
+	body := strings.ReplaceAll(buf.String(), "\n", CursorNextLine)
+
+	tty.WriteString("%s", body)
+
+	return nil
+}
+
+func (s *DisplayLogScreen) HandleKey(ctx context.Context, key KeyEvent) (bool, error) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	s.changed[s.log.ARN()] = true
+	action, ok := s.keys.Read(s.keymap, displayLogActions, key)
+	if !ok {
+		return true, nil
+	}
+	if s.grouped[s.log.ARN()] && s.handleInvocationAction(action) {
+		return true, nil
+	}
+	switch action {
+	case ActionBack:
+		for _, stop := range s.stops {
+			stop()
+		}
+		for _, stream := range s.streams {
+			stream.Close()
+		}
+		s.back(s.logs)
+	case ActionDown:
+		s.cursorDown(ctx, 1)
+	case ActionUp:
+		s.cursorUp(ctx, 1)
+	case ActionDisplayPageDown:
+		s.cursorDown(ctx, s.listRows(s.log.ARN())-1)
+	case ActionDisplayPageUp:
+		s.cursorUp(ctx, s.listRows(s.log.ARN())-1)
+	case ActionTop:
+		s.cursorUp(ctx, len(s.buffers[s.log.ARN()]))
+	case ActionBottom:
+		s.cursorDown(ctx, len(s.buffers[s.log.ARN()]))
+	case ActionDisplayNextGroup:
+		s.next(ctx)
+	case ActionDisplayPrevGroup:
+		s.prev(ctx)
+	case ActionDisplayWrap:
+		s.wrap = !s.wrap
+	case ActionDisplayToggleLive:
+		if len(s.buffers[s.log.ARN()]) == 0 {
+			return true, nil
+		}
+		s.live[s.log.ARN()] = !s.live[s.log.ARN()]
+	case ActionDisplayDetail:
+		s.viewMode(ctx)
+	case ActionCancel:
+		if s.view[s.log.ARN()] == viewModeAlt {
+			s.viewMode(ctx)
+		} else {
+			delete(s.selection, s.log.ARN())
+		}
+	case ActionDisplaySplit:
+		s.cycleSplit()
+	case ActionDisplayFocusNext:
+		s.focus(1)
+	case ActionDisplayFocusPrev:
+		s.focus(-1)
+	case ActionDisplayGrow:
+		s.resizePane(1)
+	case ActionDisplayShrink:
+		s.resizePane(-1)
+	case ActionDisplayZoom:
+		if s.split != splitNone {
+			s.zoom = !s.zoom
+		}
+	case ActionDisplayHistogram:
+		s.chart = !s.chart
+		for _, log := range s.logs {
+			s.changed[log.ARN()] = true
+		}
+	case ActionDisplayPrevBucket:
+		s.stepBucket(-1)
+	case ActionDisplayNextBucket:
+		s.stepBucket(1)
+	case ActionDisplayBookmark:
+		s.toggleBookmark()
+	case ActionDisplayBookmarks:
+		s.panel = !s.panel
+		for _, log := range s.logs {
+			s.changed[log.ARN()] = true
+		}
+	case ActionDisplayNextBookmark:
+		s.stepBookmark(1)
+	case ActionDisplayPrevBookmark:
+		s.stepBookmark(-1)
+	case ActionDisplayFollow:
+		if s.follow != nil {
+			s.follow(s.correlated(""))
+		}
+	case ActionDisplayInvocations:
+		if isLambda(s.log) && s.view[s.log.ARN()] == viewModeStream {
+			s.grouped[s.log.ARN()] = true
+		}
+	}
+	return true, nil
+}
+
+func (s *DisplayLogScreen) HandleMouse(ctx context.Context, mouse MouseEvent) (bool, error) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	if panel := s.panelWidth(); panel > 0 && mouse.X > s.col-panel {
+		if mouse.Button == MouseLeft && !mouse.Release && !mouse.Motion {
+			s.clickPanel(mouse.Y)
+		}
+		return true, nil
+	}
+	// Presses focus the pane under the mouse. Drags and releases stay with
+	// the focused pane, and rows are counted from its top.
+	if p, ok := s.paneAt(mouse.X, mouse.Y); ok && !mouse.Release && !mouse.Motion && p.log != s.log {
+		s.log = p.log
+		s.changed[s.log.ARN()] = true
+	}
+	panes := s.layout()
+	y := mouse.Y
+	for _, p := range panes {
+		if p.log == s.log {
+			y -= p.top
+		}
+	}
+	if s.grouped[s.log.ARN()] && (mouse.Button != MouseLeft || y >= 2) {
+		s.handleInvocationMouse(mouse, y)
+		return true, nil
+	}
+	switch mouse.Button {
+	case MouseWheelUp:
+		s.cursorUp(ctx, 1)
+	case MouseWheelDown:
+		s.cursorDown(ctx, 1)
+	case MouseLeft:
+		switch {
+		case mouse.Release:
+			s.dragging = false
+			if r, ok := s.selection[s.log.ARN()]; ok && r.from == r.to {
+				delete(s.selection, s.log.ARN())
+			}
+		case mouse.Motion:
+			if s.dragging {
+				s.drag(ctx, y)
+			}
+		case y == 1:
+			if len(panes) == 1 {
+				s.clickTab(ctx, mouse.X)
+			}
+		case y < 2+s.chartHeight(s.log.ARN()):
+			for _, p := range panes {
+				if p.log == s.log {
+					s.clickBucket(mouse.X - p.left - 1)
 				}
+			}
+		default:
+			s.click(ctx, y)
+		}
+	}
+	return true, nil
+}
+
+// Resize scrolls every group so that its cursor stays within the events that
+// fit below the header of its pane.
+func (s *DisplayLogScreen) Resize(ctx context.Context, rows, cols int) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	s.row = rows
+	s.col = cols
+	for _, log := range s.logs {
+		arn := log.ARN()
+		s.offset[arn] = visibleOffset(s.index[arn], s.offset[arn], s.listRows(arn), len(s.buffers[arn]))
+		s.changed[arn] = true
+	}
+}
+
+// SetFilter restarts the live tail of the focused group with only the
+// events matching pattern, a CloudWatch Logs filter pattern.
+func (s *DisplayLogScreen) SetFilter(ctx context.Context, pattern string) {
+	s.rw.Lock()
+	log := s.log
+	s.patterns[log.ARN()] = pattern
+	delete(s.selection, log.ARN())
+	delete(s.errs, log.ARN())
+	delete(s.view, log.ARN())
+	s.rw.Unlock()
+	s.start(ctx, log)
+}
+
+// Backfill adds the events of every group since the given time, as far as
+// they fit in the buffers.
+func (s *DisplayLogScreen) Backfill(ctx context.Context, since time.Time) {
+	s.rw.RLock()
+	patterns := maps.Clone(s.patterns)
+	s.rw.RUnlock()
+	for _, log := range s.logs {
+		go func(log *LogGroup, pattern string) {
+			events, err := log.Events(ctx, since, pattern, MaxEvents)
+			s.rw.Lock()
+			if s.patterns[log.ARN()] != pattern {
 				s.rw.Unlock()
+				return
+			}
+			if err != nil {
+				s.errs[log.ARN()] = err
+			} else {
+				s.merge(log.ARN(), events)
 			}
-		}(ctx, log)
+			s.changed[log.ARN()] = true
+			s.rw.Unlock()
+			if s.redraw != nil {
+				s.redraw()
+			}
+		}(log, patterns[log.ARN()])
+	}
+}
+
+// merge adds the events not already buffered in order of time, keeping the
+// cursor on the event it was on.
+func (s *DisplayLogScreen) merge(arn string, events []*LogEvent) {
+	var current *LogEvent
+	if idx := s.index[arn]; 0 <= idx && idx < len(s.buffers[arn]) {
+		current = s.buffers[arn][idx]
+	}
+	buffer := unfold(s.buffers[arn])
+	type key struct {
+		timestamp int64
+		stream    string
+		msg       string
+	}
+	seen := make(map[key]bool, len(buffer))
+	for _, evt := range buffer {
+		seen[key{evt.timestamp.UnixMilli(), evt.stream, evt.msg}] = true
+	}
+	merged := slices.Clone(buffer)
+	for _, evt := range events {
+		if !seen[key{evt.timestamp.UnixMilli(), evt.stream, evt.msg}] {
+			merged = append(merged, evt)
+		}
+	}
+	slices.SortStableFunc(merged, func(a, b *LogEvent) int {
+		return a.timestamp.Compare(b.timestamp)
+	})
+	if s.fold {
+		merged = s.multiline.fold(merged)
+	}
+	merged = trimLines(merged, MaxEvents)
+	s.buffers[arn] = merged
+	s.indexInvocations(arn)
+	delete(s.selection, arn)
+	if current != nil {
+		s.index[arn] = max(indexOf(merged, current), 0)
+		s.offset[arn] = visibleOffset(s.index[arn], s.offset[arn], s.listRows(arn), len(merged))
+	}
+}
+
+// eventJSON is an event as Export writes it.
+type eventJSON struct {
+	Timestamp time.Time `json:"timestamp"`
+	LogGroup  string    `json:"logGroup"`
+	LogStream string    `json:"logStream"`
+	Message   string    `json:"message"`
+	Bookmark  bool      `json:"bookmark,omitempty"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// Export writes the selected events of the current group, or all of them
+// if none are selected, to path as a JSON array. It returns the number of
+// events written. Exporting all events includes the bookmarked ones the
+// buffer no longer holds.
+func (s *DisplayLogScreen) Export(path string) (int, error) {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	events := s.buffers[s.log.ARN()]
+	if _, ok := s.selection[s.log.ARN()]; ok {
+		from, to := s.selectedRange(s.log.ARN())
+		events = events[from : to+1]
+	} else {
+		events = slices.Clone(events)
+		for _, b := range s.bookmarks {
+			if b.log == s.log && !slices.Contains(events, b.event) {
+				events = append(events, b.event)
+			}
+		}
+		slices.SortStableFunc(events, func(a, b *LogEvent) int {
+			return a.timestamp.Compare(b.timestamp)
+		})
+	}
+	expanded := []*LogEvent{}
+	for _, evt := range events {
+		expanded = append(append(expanded, evt), evt.folded...)
 	}
+	events = expanded
+	out := make([]eventJSON, len(events))
+	for i, evt := range events {
+		out[i] = eventJSON{
+			Timestamp: evt.Timestamp(),
+			LogGroup:  s.log.Name(),
+			LogStream: evt.LogStream(),
+			Message:   evt.Message(),
+		}
+		if j := s.bookmarkIndex(evt); j >= 0 {
+			out[i].Bookmark = true
+			out[i].Note = s.bookmarks[j].note
+		}
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	return len(out), os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// Follow opens the timeline of id, or of the correlation ID of the event
+// under the cursor if id is empty.
+func (s *DisplayLogScreen) Follow(id string) {
+	s.rw.RLock()
+	ids, events := s.correlated(id)
+	s.rw.RUnlock()
+	if s.follow != nil {
+		s.follow(ids, events)
+	}
+}
+
+// IDs returns the correlation IDs of the event under the cursor.
+func (s *DisplayLogScreen) IDs() []string {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	if evt := s.current(); evt != nil {
+		return s.correlator.eventIDs(evt)
+	}
+	return nil
+}
+
+// correlated returns id, or the correlation IDs of the event under the cursor
+// if id is empty. If there is one ID, it also returns the buffered events of
+// every group that contain it in time order.
+func (s *DisplayLogScreen) correlated(id string) ([]string, []timelineEvent) {
+	ids := []string{id}
+	if id == "" {
+		evt := s.current()
+		if evt == nil {
+			return nil, nil
+		}
+		ids = s.correlator.eventIDs(evt)
+		if len(ids) != 1 {
+			return ids, nil
+		}
+	}
+	events := []timelineEvent{}
+	for _, log := range s.logs {
+		for _, evt := range s.buffers[log.ARN()] {
+			if mentions(evt, ids[0]) {
+				events = append(events, timelineEvent{log: log, event: evt})
+			}
+		}
+	}
+	slices.SortStableFunc(events, func(a, b timelineEvent) int {
+		return a.event.timestamp.Compare(b.event.timestamp)
+	})
+	return ids, events
+}
+
+// Reveal focuses log and pauses it on evt, adding evt to its buffer if it is
+// not there.
+func (s *DisplayLogScreen) Reveal(log *LogGroup, evt *LogEvent) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	if !slices.Contains(s.buffers[log.ARN()], evt) {
+		s.merge(log.ARN(), []*LogEvent{evt})
+	}
+	s.jumpToEvent(log, evt)
+}
+
+// ShowGroup switches the focused pane to the group named name.
+func (s *DisplayLogScreen) ShowGroup(name string) error {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	for _, log := range s.logs {
+		if log.Name() == name || log.ARN() == name {
+			s.show(log)
+			return nil
+		}
+	}
+	return fmt.Errorf("no such log group: %s", name)
+}
+
+// SetLocation shows timestamps in loc.
+func (s *DisplayLogScreen) SetLocation(loc *time.Location) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	s.location = loc
+	s.changed[s.log.ARN()] = true
 }
 
-func (s *DisplayLogScreen) Render(ctx context.Context, tty *TTY) error {
-	if !s.changed[s.log.ARN()] {
-		return nil
+// Set turns an option on, or off when prefixed with "no", as in "wrap" and
+// "nowrap". A "!" suffix toggles it.
+func (s *DisplayLogScreen) Set(option string) error {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	name, toggle := strings.CutSuffix(option, "!")
+	name, off := strings.CutPrefix(name, "no")
+	arn := s.log.ARN()
+	on := !off
+	switch name {
+	case "wrap":
+		if toggle {
+			on = !s.wrap
+		}
+		s.wrap = on
+	case "live":
+		if toggle {
+			on = !s.live[arn]
+		}
+		if len(s.buffers[arn]) > 0 {
+			s.live[arn] = on
+		}
+	case "fold":
+		if toggle {
+			on = !s.fold
+		}
+		s.setFold(on)
+	default:
+		return fmt.Errorf("unknown option: %s", option)
 	}
-	s.changed[s.log.ARN()] = false
+	s.changed[arn] = true
+	return nil
+}
 
-	if err := tty.Clear(); err != nil {
-		return err
+func (s *DisplayLogScreen) timestamp(evt *LogEvent) string {
+	if s.location == nil {
+		return evt.Timestamp().Format(timestampLayout)
 	}
+	return evt.Timestamp().In(s.location).Format(timestampLayout)
+}
 
-	row, col, _, _, err := tty.Size()
-	if err != nil {
-		return err
-	}
+func (s *DisplayLogScreen) Actions() []Action {
+	return displayLogActions
+}
 
+func (s *DisplayLogScreen) Status() Status {
 	s.rw.RLock()
 	defer s.rw.RUnlock()
-
-	s.handleViewMode(ctx, tty)
-
-	live := s.live[s.log.ARN()]
-
-	buf := bytes.NewBuffer(nil)
-
-	buf.WriteString(fmt.Sprintf("\x1b[32m%s\x1b[0m", s.log.ARN()))
-	buf.WriteString(" ")
-	status := "paused"
-	if live {
-		status = "live"
+	arn := s.log.ARN()
+	status := Status{
+		Mode:     "stream",
+		Live:     "paused",
+		Index:    slices.Index(s.logs, s.log) + 1,
+		Total:    len(s.logs),
+		Buffered: countLines(s.buffers[arn]),
+		Capacity: MaxEvents,
+		Err:      s.errs[arn],
 	}
-	buf.WriteString(fmt.Sprintf("\x1b[32m%s\x1b[0m", status))
-	buf.WriteString("\n")
+	if s.view[arn] != viewModeStream {
+		status.Mode = "detail"
+	}
+	if s.grouped[arn] {
+		status.Mode = "invocations"
+	}
+	switch {
+	case s.zoom:
+		status.Mode += " zoom"
+	case len(s.layout()) > 1:
+		status.Mode += fmt.Sprintf(" %d panes", len(s.panes))
+	}
+	switch {
+	case s.errs[arn] != nil:
+		status.Live = ""
+	case s.streams[arn] == nil:
+		status.Live = "connecting"
+	case s.live[arn]:
+		status.Live = "live"
+	}
+	return status
+}
 
+func (s *DisplayLogScreen) click(ctx context.Context, y int) {
 	if len(s.buffers[s.log.ARN()]) == 0 {
-		body := strings.ReplaceAll(buf.String(), "\n", CursorNextLine)
-		tty.WriteString("%s", body)
-		return nil
+		return
+	}
+	clickidx := s.eventAt(y)
+	curidx := s.index[s.log.ARN()]
+	s.live[s.log.ARN()] = false
+	s.index[s.log.ARN()] = clickidx
+	s.changed[s.log.ARN()] = true
+	if clickidx == curidx {
+		s.viewMode(ctx)
+		return
 	}
+	s.selection[s.log.ARN()] = eventRange{from: clickidx, to: clickidx}
+	s.dragging = true
+}
 
-	view := s.view[s.log.ARN()]
-	if view == viewModeAlt {
+// drag extends the selection to the event at row y, scrolling when the mouse
+// is dragged past the top or bottom of the events.
+func (s *DisplayLogScreen) drag(ctx context.Context, y int) {
+	r, ok := s.selection[s.log.ARN()]
+	if !ok {
+		return
+	}
+	index := s.index[s.log.ARN()]
+	target := s.eventAt(y)
+	switch {
+	case y < 2+s.chartHeight(s.log.ARN()):
+		s.cursorUp(ctx, 1)
+	case y >= s.paneRows(s.log.ARN()):
+		s.cursorDown(ctx, 1)
+	case target < index:
+		s.cursorUp(ctx, index-target)
+	case target > index:
+		s.cursorDown(ctx, target-index)
+	}
+	r.to = s.index[s.log.ARN()]
+	s.selection[s.log.ARN()] = r
+	s.changed[s.log.ARN()] = true
+}
 
-		idx := s.index[s.log.ARN()]
-		log := s.buffers[s.log.ARN()][idx]
+// eventAt returns the index of the event rendered at row y of the focused
+// pane.
+func (s *DisplayLogScreen) eventAt(y int) int {
+	lastidx := len(s.buffers[s.log.ARN()]) - 1
+	lines := s.lines[s.log.ARN()]
+	i := y - 2 - s.chartHeight(s.log.ARN())
+	switch {
+	case i < 0:
+		return min(s.offset[s.log.ARN()], lastidx)
+	case i < len(lines):
+		return min(lines[i], lastidx)
+	}
+	return lastidx
+}
 
-		message := log.Message()
+// scrollWrapped moves the offset so that the cursor stays within height rows
+// when events take more than one row each, and in live mode fills the rows
+// up to the last event.
+func (s *DisplayLogScreen) scrollWrapped(arn string, width, height int) {
+	events := s.buffers[arn]
+	idx := s.index[arn]
+	if idx < 0 || idx >= len(events) {
+		return
+	}
+	rows := func(i int) int {
+		return len(Wrap(printable(events[i].Message()), width))
+	}
 
-		var b []byte
-		// try json.Unmarshal
-		var jsonData map[string]interface{}
-		if err := json.Unmarshal([]byte(message), &jsonData); err == nil {
-			b, _ = json.MarshalIndent(jsonData, "", "  ")
-		} else {
-			b = []byte(message)
+	offset := s.offset[arn]
+	if s.live[arn] {
+		offset = idx
+		used := rows(idx)
+		for offset > 0 && used+rows(offset-1) <= height {
+			offset--
+			used += rows(offset)
+		}
+	} else {
+		offset = min(offset, idx)
+		used := 0
+		for i := offset; i <= idx; i++ {
+			used += rows(i)
+		}
+		for used > height && offset < idx {
+			used -= rows(offset)
+			offset++
 		}
-
-		body := strings.ReplaceAll(string(b), "\n", CursorNextLine)
-		tty.WriteString("%s", body)
-
-		return nil
 	}
+	s.offset[arn] = offset
+}
 
-	s.row = row
-	s.col = col
-
-	rows := row - 2
-
-	allEvents := s.buffers[s.log.ARN()]
-	lastidx := len(s.buffers[s.log.ARN()]) - 1
+// histogram returns the histogram of the events of arn drawn in cols
+// columns, beside its scale, up to the last event.
+func (s *DisplayLogScreen) histogram(arn string, cols int) histogram {
+	events := s.buffers[arn]
+	first, end := time.Now(), time.Now()
+	if len(events) > 0 {
+		first, end = events[0].timestamp, events[len(events)-1].timestamp
+	}
+	return newHistogram(events, first, end, cols-scaleWidth)
+}
 
-	if live {
-		offset := lastidx - rows
-		if offset < 0 {
-			offset = 0
+// sparkline returns the sparkline of arn in the group tabs. The sparklines of
+// all groups share their buckets so that they compare.
+func (s *DisplayLogScreen) sparkline(arn string) string {
+	var first, end time.Time
+	for _, log := range s.logs {
+		events := s.buffers[log.ARN()]
+		if len(events) == 0 {
+			continue
+		}
+		if first.IsZero() || events[0].timestamp.Before(first) {
+			first = events[0].timestamp
+		}
+		if last := events[len(events)-1].timestamp; last.After(end) {
+			end = last
 		}
-		s.offset[s.log.ARN()] = offset
-		s.index[s.log.ARN()] = lastidx
 	}
+	return newHistogram(s.buffers[arn], first, end, sparklineBuckets).sparkline(s.theme)
+}
 
-	idx := s.index[s.log.ARN()]
-	offset := s.offset[s.log.ARN()]
-	limit := offset + rows + 1
-	if limit > lastidx {
-		limit = lastidx + 1
+// clickBucket moves the cursor to the first event in bucket i of the focused
+// pane's histogram.
+func (s *DisplayLogScreen) clickBucket(i int) {
+	h, ok := s.histograms[s.log.ARN()]
+	if !ok || i < 0 || i >= len(h.buckets) {
+		return
 	}
-	events := allEvents[offset:limit]
+	s.jumpTo(h.window(i))
+}
 
-	for i, evt := range events {
-		evtidx := i + offset
-		timestamp := evt.Timestamp().Format("2006-01-02 15:04:05")
-		message := evt.Message()
-		chars := len(timestamp) + len(message) + 1
-		overflow := col - chars
-		if overflow < 0 {
-			messageLen := len(message) + overflow - 3
-			if messageLen < 0 {
-				message = ""
-			} else {
-				message = message[:messageLen] + "..."
-			}
+// stepBucket moves the cursor to the first event of the next bucket with
+// events after the one of the cursor, or before it if step is negative.
+func (s *DisplayLogScreen) stepBucket(step int) {
+	arn := s.log.ARN()
+	events := s.buffers[arn]
+	if len(events) == 0 {
+		return
+	}
+	h, ok := s.histograms[arn]
+	if !ok || s.chartHeight(arn) == 0 {
+		h = s.histogram(arn, s.col)
+	}
+	idx := min(max(s.index[arn], 0), len(events)-1)
+	for i := h.bucket(events[idx].timestamp) + step; 0 <= i && i < len(h.buckets); i += step {
+		if h.total(i) > 0 {
+			s.jumpTo(h.window(i))
+			return
 		}
+	}
+}
 
-		line := ""
-		if evtidx == idx {
-			line += "\x1b[7m"
+// jumpToEvent focuses log and pauses it on evt, or on the first event after
+// it if the buffer does not hold it.
+func (s *DisplayLogScreen) jumpToEvent(log *LogGroup, evt *LogEvent) {
+	s.show(log)
+	arn := log.ARN()
+	events := s.buffers[arn]
+	if len(events) == 0 {
+		return
+	}
+	idx := slices.Index(events, evt)
+	if idx < 0 {
+		idx = slices.IndexFunc(events, func(e *LogEvent) bool {
+			return !e.timestamp.Before(evt.timestamp)
+		})
+		if idx < 0 {
+			idx = len(events) - 1
 		}
-		line += fmt.Sprintf("\x1b[32m%s", timestamp)
-		line += " "
-		line += fmt.Sprintf("\x1b[33m%s", message)
-		line += "\x1b[0m"
-		buf.WriteString(line)
-		buf.WriteString("\n")
 	}
-
-	body := strings.ReplaceAll(buf.String(), "\n", CursorNextLine)
-
-	body = regexp.MustCompile(`ERROR`).ReplaceAllString(body, "\x1b[31m$0\x1b[33m")
-	body = regexp.MustCompile(`INFO`).ReplaceAllString(body, "\x1b[32m$0\x1b[33m")
-	body = regexp.MustCompile(`WARN`).ReplaceAllString(body, "\x1b[35m$0\x1b[33m")
-	body = regexp.MustCompile(`DEBUG`).ReplaceAllString(body, "\x1b[34m$0\x1b[33m")
-
-	tty.WriteString("%s", body)
-
-	return nil
+	s.live[arn] = false
+	s.index[arn] = idx
+	s.offset[arn] = visibleOffset(idx, s.offset[arn], s.listRows(arn), len(events))
+	delete(s.selection, arn)
+	s.changed[arn] = true
 }
 
-func (s *DisplayLogScreen) HandleInput(ctx context.Context, r rune) (bool, error) {
-	s.rw.Lock()
-	defer s.rw.Unlock()
-	s.changed[s.log.ARN()] = true
-	switch r {
-	case 127: // Backspace
-		for _, stream := range s.streams {
-			stream.Close()
+// jumpTo pauses the focused group and moves its cursor to the first event
+// from start until end.
+func (s *DisplayLogScreen) jumpTo(start, end time.Time) {
+	arn := s.log.ARN()
+	for i, evt := range s.buffers[arn] {
+		if !evt.timestamp.Before(start) && evt.timestamp.Before(end) {
+			s.live[arn] = false
+			s.index[arn] = i
+			s.offset[arn] = visibleOffset(i, s.offset[arn], s.listRows(arn), len(s.buffers[arn]))
+			delete(s.selection, arn)
+			s.changed[arn] = true
+			return
 		}
-		s.back(s.logs)
-	case 'j':
-		s.cursorDown(ctx, 1)
-	case 'k':
-		s.cursorUp(ctx, 1)
-	case 'J':
-		s.cursorDown(ctx, s.row-2)
-	case 'K':
-		s.cursorUp(ctx, s.row-2)
-	case 'l':
-		s.next(ctx)
-	case 'h':
-		s.prev(ctx)
-	case ',': // Toggle Live Mode
-		if len(s.buffers[s.log.ARN()]) == 0 {
-			return true, nil
+	}
+}
+
+func (s *DisplayLogScreen) clickTab(_ context.Context, x int) {
+	for i, tab := range s.tabs {
+		if tab[0] <= x && x <= tab[1] {
+			s.show(s.logs[i])
+			return
 		}
-		s.live[s.log.ARN()] = !s.live[s.log.ARN()]
-	case ' ':
-		s.viewMode(ctx)
 	}
-	return true, nil
 }
 
-func (s *DisplayLogScreen) HandleCtrl(ctx context.Context, ctrl string) (bool, error) {
-	s.rw.Lock()
-	defer s.rw.Unlock()
-	switch ctrl {
-	case CursorUp:
-		s.cursorUp(ctx, 1)
-	case CursorDown:
-		s.cursorDown(ctx, 1)
-	case CursorRight:
-		s.next(ctx)
-	case CursorLeft:
-		s.prev(ctx)
+func (s *DisplayLogScreen) inSelection(arn string, idx int) bool {
+	r, ok := s.selection[arn]
+	if !ok {
+		return false
 	}
-	return true, nil
+	from, to := r.bounds()
+	return from <= idx && idx <= to
 }
 
-func (s *DisplayLogScreen) HandleMouse(ctx context.Context, code, x, y int) (bool, error) {
-	s.rw.Lock()
-	defer s.rw.Unlock()
-	switch code {
-	case 0x00: // Left Click
-		if y < 2 {
-			return true, nil
-		}
-		if len(s.buffers[s.log.ARN()]) == 0 {
-			return true, nil
-		}
-		lastidx := len(s.buffers[s.log.ARN()]) - 1
-		clickidx := s.offset[s.log.ARN()] + y - 2
-		if clickidx > lastidx {
-			clickidx = lastidx
-		}
-		if clickidx < 0 {
-			clickidx = 0
-		}
-		curidx := s.index[s.log.ARN()]
-		s.index[s.log.ARN()] = clickidx
-		s.changed[s.log.ARN()] = true
-		if clickidx == curidx {
-			s.viewMode(ctx)
-		}
-	case 0x40: // Wheel Up
-		s.cursorUp(ctx, 1)
-	case 0x41: // Wheel Down
-		s.cursorDown(ctx, 1)
+// selectedRange returns the events to show in the detail view: the dragged
+// range if there is one, or else the event under the cursor.
+func (s *DisplayLogScreen) selectedRange(arn string) (int, int) {
+	lastidx := len(s.buffers[arn]) - 1
+	idx := min(max(s.index[arn], 0), lastidx)
+	r, ok := s.selection[arn]
+	if !ok {
+		return idx, idx
 	}
-	return true, nil
+	from, to := r.bounds()
+	return min(max(from, 0), lastidx), min(to, lastidx)
 }
 
 func (s *DisplayLogScreen) cursorUp(_ context.Context, move int) {
@@ -646,7 +2401,7 @@ func (s *DisplayLogScreen) cursorDown(_ context.Context, move int) {
 
 	offset := s.offset[s.log.ARN()]
 
-	botidx := s.row + offset - 2
+	botidx := s.listRows(s.log.ARN()) + offset - 1
 	if botidx > lastidx {
 		botidx = lastidx
 	}
@@ -665,20 +2420,65 @@ func (s *DisplayLogScreen) cursorDown(_ context.Context, move int) {
 }
 
 func (s *DisplayLogScreen) next(_ context.Context) {
-	next := slices.Index(s.logs, s.log) + 1
-	if next >= len(s.logs) {
-		next = 0
+	s.show(s.nextGroup(1))
+}
+
+func (s *DisplayLogScreen) prev(_ context.Context) {
+	s.show(s.nextGroup(-1))
+}
+
+// nextGroup returns the group step groups away from the focused one,
+// skipping the groups shown in other panes.
+func (s *DisplayLogScreen) nextGroup(step int) *LogGroup {
+	i := slices.Index(s.logs, s.log)
+	for range s.logs {
+		i = (i + step + len(s.logs)) % len(s.logs)
+		if log := s.logs[i]; log == s.log || !slices.Contains(s.panes, log) || s.split == splitNone {
+			return log
+		}
+	}
+	return s.log
+}
+
+// show focuses log, putting it in place of the focused pane.
+func (s *DisplayLogScreen) show(log *LogGroup) {
+	if i := slices.Index(s.panes, s.log); i >= 0 && !slices.Contains(s.panes, log) {
+		s.panes[i] = log
 	}
-	s.log = s.logs[next]
+	s.log = log
 	s.changed[s.log.ARN()] = true
 }
 
-func (s *DisplayLogScreen) prev(_ context.Context) {
-	next := slices.Index(s.logs, s.log) - 1
-	if next < 0 {
-		next = len(s.logs) - 1
+// cycleSplit switches from one pane to panes side by side, to stacked panes
+// and back.
+func (s *DisplayLogScreen) cycleSplit() {
+	if len(s.panes) < 2 {
+		return
+	}
+	s.split = (s.split + 1) % 3
+	s.zoom = false
+	if !slices.Contains(s.panes, s.log) {
+		s.panes[0] = s.log
+	}
+	s.changed[s.log.ARN()] = true
+}
+
+// focus moves the focus step panes away.
+func (s *DisplayLogScreen) focus(step int) {
+	if s.split == splitNone {
+		return
+	}
+	i := slices.Index(s.panes, s.log)
+	s.log = s.panes[(i+step+len(s.panes))%len(s.panes)]
+	s.changed[s.log.ARN()] = true
+}
+
+// resizePane changes the weight of the focused pane by step.
+func (s *DisplayLogScreen) resizePane(step int) {
+	if s.split == splitNone || s.zoom {
+		return
 	}
-	s.log = s.logs[next]
+	s.weights[s.log.ARN()] = min(max(s.weight(s.log)+step, 1), maxWeight)
 	s.changed[s.log.ARN()] = true
 }
 
@@ -704,7 +2504,7 @@ func (s *DisplayLogScreen) viewMode(_ context.Context) {
 	s.changed[s.log.ARN()] = true
 }
 
-func (s *DisplayLogScreen) handleViewMode(_ context.Context, tty *TTY) {
+func (s *DisplayLogScreen) handleViewMode(_ context.Context, tty Terminal) {
 	if len(s.buffers[s.log.ARN()]) == 0 {
 		return
 	}
@@ -725,3 +2525,263 @@ func (s *DisplayLogScreen) handleViewMode(_ context.Context, tty *TTY) {
 		return
 	}
 }
+
+// timelineEvent is an event of a timeline and the group it belongs to.
+type timelineEvent struct {
+	log   *LogGroup
+	event *LogEvent
+}
+
+// TimelineScreen lists the events of several groups that contain a
+// correlation ID, such as a trace or request ID, in time order.
+type TimelineScreen struct {
+	mu       sync.Mutex
+	id       string
+	logs     []*LogGroup
+	events   []timelineEvent
+	index    int
+	offset   int
+	limit    int
+	hints    []hint
+	pending  int
+	err      error
+	open     func(*LogGroup, *LogEvent)
+	back     func()
+	redraw   func()
+	theme    *Theme
+	keymap   *Keymap
+	keys     keySequence
+	location *time.Location
+}
+
+var timelineActions = []Action{
+	ActionUp, ActionDown, ActionTop, ActionBottom, ActionTimelinePageUp, ActionTimelinePageDown,
+	ActionApply, ActionTimelineBackfill, ActionBack, ActionCancel, ActionHelp, ActionCommand,
+}
+
+var timelineHints = []hint{
+	{actions: []Action{ActionUp, ActionDown}, label: "up/down"},
+	{actions: []Action{ActionApply}, label: "open"},
+	{actions: []Action{ActionTimelineBackfill}, label: "search other groups"},
+	{actions: []Action{ActionBack}, label: "back"},
+}
+
+const (
+	// timelineBackfill is how long before the first event of a timeline
+	// the groups without the ID are searched from.
+	timelineBackfill = time.Hour
+	// maxTimelineNameWidth is the widest the group column of a timeline
+	// gets.
+	maxTimelineNameWidth = 24
+)
+
+// NewTimelineScreen lists events, the events of logs that contain id. open
+// is called with the chosen event.
+func NewTimelineScreen(id string, logs []*LogGroup, events []timelineEvent, open func(*LogGroup, *LogEvent), back func()) *TimelineScreen {
+	return &TimelineScreen{
+		id:     id,
+		logs:   logs,
+		events: events,
+		open:   open,
+		back:   back,
+		theme:  DefaultTheme,
+		keymap: DefaultKeymap,
+	}
+}
+
+func (s *TimelineScreen) Init(ctx context.Context) {
+}
+
+func (s *TimelineScreen) Render(ctx context.Context, tty Terminal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := tty.Clear(); err != nil {
+		return err
+	}
+
+	row, col, _, _, err := tty.Size()
+	if err != nil {
+		return err
+	}
+
+	groups := map[*LogGroup]bool{}
+	width := 0
+	for _, e := range s.events {
+		groups[e.log] = true
+		width = max(width, min(StringWidth(e.log.Name()), maxTimelineNameWidth))
+	}
+	tty.WriteString("%s %s %d events in %d/%d groups", s.theme.Title.Paint("Timeline"), s.id, len(s.events), len(groups), len(s.logs))
+	if s.pending > 0 {
+		tty.WriteString(" %s", s.theme.Pending.Paint(fmt.Sprintf("searching %d groups...", s.pending)))
+	}
+	tty.NextLine(1)
+	s.hints = renderHints(tty, s.keymap, timelineHints)
+	tty.NextLine(2)
+
+	s.limit = max(row-3, 1)
+	s.offset = visibleOffset(s.index, s.offset, s.limit, len(s.events))
+	for i := s.offset; i < len(s.events) && i < s.offset+s.limit; i++ {
+		e := s.events[i]
+		timestamp := e.event.Timestamp()
+		if s.location != nil {
+			timestamp = timestamp.In(s.location)
+		}
+		name := Truncate(e.log.Name(), width, "...")
+		name += strings.Repeat(" ", width-StringWidth(name))
+		message := Truncate(printable(strings.ReplaceAll(e.event.Message(), "\n", " ")), col-len(timestampLayout)-width-2, "...")
+
+		tsStyle, nameStyle, msgStyle, idStyle := s.theme.Timestamp, s.theme.Header, s.theme.Message, s.theme.Warn
+		if i == s.index {
+			tsStyle = tsStyle.Merge(s.theme.Selected)
+			nameStyle = nameStyle.Merge(s.theme.Selected)
+			msgStyle = msgStyle.Merge(s.theme.Selected)
+			idStyle = idStyle.Merge(s.theme.Selected)
+		}
+		parts := strings.Split(message, s.id)
+		for j := range parts {
+			parts[j] = msgStyle.Paint(parts[j])
+		}
+		tty.WriteString("%s%s%s", tsStyle.Paint(timestamp.Format(timestampLayout)+" "), nameStyle.Paint(name+" "), strings.Join(parts, idStyle.Paint(s.id)))
+		tty.NextLine(1)
+	}
+	return nil
+}
+
+func (s *TimelineScreen) HandleKey(ctx context.Context, key KeyEvent) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	action, ok := s.keys.Read(s.keymap, timelineActions, key)
+	if !ok {
+		return true, nil
+	}
+	return s.handleAction(ctx, action)
+}
+
+func (s *TimelineScreen) handleAction(ctx context.Context, action Action) (bool, error) {
+	last := max(len(s.events)-1, 0)
+	switch action {
+	case ActionBack, ActionCancel:
+		s.back()
+	case ActionUp:
+		s.index = max(s.index-1, 0)
+	case ActionDown:
+		s.index = min(s.index+1, last)
+	case ActionTop:
+		s.index = 0
+	case ActionBottom:
+		s.index = last
+	case ActionTimelinePageUp:
+		s.index = max(s.index-s.limit, 0)
+	case ActionTimelinePageDown:
+		s.index = min(s.index+s.limit, last)
+	case ActionApply:
+		if s.index < len(s.events) {
+			s.open(s.events[s.index].log, s.events[s.index].event)
+		}
+	case ActionTimelineBackfill:
+		s.backfill(ctx)
+	}
+	return true, nil
+}
+
+// backfill searches the groups without events of the ID with
+// FilterLogEvents, from timelineBackfill before the first event.
+func (s *TimelineScreen) backfill(ctx context.Context) {
+	if s.pending > 0 {
+		return
+	}
+	s.err = nil
+	since := time.Now().Add(-timelineBackfill)
+	if len(s.events) > 0 {
+		since = s.events[0].event.timestamp.Add(-timelineBackfill)
+	}
+	pattern := `"` + s.id + `"`
+	for _, log := range s.logs {
+		if slices.ContainsFunc(s.events, func(e timelineEvent) bool { return e.log == log }) {
+			continue
+		}
+		s.pending++
+		go func(log *LogGroup) {
+			events, err := log.Events(ctx, since, pattern, MaxEvents)
+			s.mu.Lock()
+			s.pending--
+			if err != nil {
+				s.err = fmt.Errorf("%s: %w", log.Name(), err)
+			}
+			s.add(log, events)
+			s.mu.Unlock()
+			if s.redraw != nil {
+				s.redraw()
+			}
+		}(log)
+	}
+}
+
+// add merges events of log into the timeline, keeping the cursor on its
+// event.
+func (s *TimelineScreen) add(log *LogGroup, events []*LogEvent) {
+	var current *LogEvent
+	if s.index < len(s.events) {
+		current = s.events[s.index].event
+	}
+	for _, evt := range events {
+		s.events = append(s.events, timelineEvent{log: log, event: evt})
+	}
+	slices.SortStableFunc(s.events, func(a, b timelineEvent) int {
+		return a.event.timestamp.Compare(b.event.timestamp)
+	})
+	if current != nil {
+		s.index = max(slices.IndexFunc(s.events, func(e timelineEvent) bool { return e.event == current }), 0)
+	}
+}
+
+func (s *TimelineScreen) HandleMouse(ctx context.Context, mouse MouseEvent) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mouse.Release || mouse.Motion {
+		return true, nil
+	}
+	switch mouse.Button {
+	case MouseWheelUp:
+		return s.handleAction(ctx, ActionUp)
+	case MouseWheelDown:
+		return s.handleAction(ctx, ActionDown)
+	case MouseLeft:
+		if mouse.Y == 2 {
+			if action, ok := clickedHint(s.hints, mouse.X); ok {
+				return s.handleAction(ctx, action)
+			}
+			return true, nil
+		}
+		clickidx := s.offset + mouse.Y - 4
+		if mouse.Y < 4 || clickidx >= len(s.events) {
+			return true, nil
+		}
+		if clickidx == s.index {
+			return s.handleAction(ctx, ActionApply)
+		}
+		s.index = clickidx
+	}
+	return true, nil
+}
+
+func (s *TimelineScreen) Resize(ctx context.Context, rows, cols int) {
+}
+
+func (s *TimelineScreen) Actions() []Action {
+	return timelineActions
+}
+
+func (s *TimelineScreen) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{Mode: "timeline", Err: s.err}
+	if len(s.events) > 0 {
+		status.Index, status.Total = s.index+1, len(s.events)
+	}
+	if s.pending > 0 {
+		status.Live = "searching"
+	}
+	return status
+}
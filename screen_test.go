@@ -0,0 +1,620 @@
+package cwl
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// testTime is when the events of the tests are written.
+var testTime = time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+
+func testEvent(at time.Duration, stream, msg string) types.LiveTailSessionLogEvent {
+	return types.LiveTailSessionLogEvent{
+		Message:       aws.String(msg),
+		LogStreamName: aws.String(stream),
+		Timestamp:     aws.Int64(testTime.Add(at).UnixMilli()),
+	}
+}
+
+// testEvents returns n events written a second apart.
+func testEvents(n int) []*LogEvent {
+	events := make([]*LogEvent, n)
+	for i := range events {
+		events[i] = NewLogEvent(testEvent(time.Duration(i)*time.Second, "stream", fmt.Sprintf("event %d", i)))
+	}
+	return events
+}
+
+func testLogGroups(t *testing.T, client Client, n int) []*LogGroup {
+	t.Helper()
+	logs := make([]*LogGroup, n)
+	for i, logGroup := range fakeLogGroups("111111111111", "us-east-1", n) {
+		logs[i] = mustLogGroup(t, client, "dev", "", logGroup)
+	}
+	return logs
+}
+
+// pressKeys sends the keys, as ParseKey parses them, to screen.
+func pressKeys(t *testing.T, screen Screen, keys ...string) {
+	t.Helper()
+	for _, text := range keys {
+		key, err := ParseKey(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := screen.HandleKey(context.Background(), key); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+}
+
+func repeat(key string, n int) []string {
+	return slices.Repeat([]string{key}, n)
+}
+
+func TestChooseLogsScreenHandleKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []string
+		index    int
+		offset   int
+		selected []string
+		filtered int
+		applied  []string
+	}{
+		{
+			name:  "down",
+			keys:  repeat("j", 3),
+			index: 3,
+		},
+		{
+			name:   "down past the page",
+			keys:   repeat("j", 11),
+			index:  11,
+			offset: 2,
+		},
+		{
+			name:   "up wraps to the bottom",
+			keys:   []string{"k"},
+			index:  24,
+			offset: 15,
+		},
+		{
+			name:   "down wraps to the top",
+			keys:   []string{"G", "j"},
+			index:  0,
+			offset: 0,
+		},
+		{
+			name:   "bottom",
+			keys:   []string{"end"},
+			index:  24,
+			offset: 15,
+		},
+		{
+			name: "top",
+			keys: []string{"G", "g", "g"},
+		},
+		{
+			name:   "page down",
+			keys:   []string{"pgdn"},
+			index:  11,
+			offset: 11,
+		},
+		{
+			name:   "page up",
+			keys:   []string{"pgdn", "h"},
+			index:  1,
+			offset: 1,
+		},
+		{
+			name:     "select",
+			keys:     []string{"j", "space", "j", "space"},
+			index:    2,
+			selected: []string{"/app/01", "/app/02"},
+		},
+		{
+			name: "unselect",
+			keys: []string{"space", "space"},
+		},
+		{
+			name:     "search",
+			keys:     []string{"/", "0", "3", "enter"},
+			filtered: 1,
+		},
+		{
+			name:     "search cancelled",
+			keys:     []string{"/", "0", "esc"},
+			filtered: 25,
+		},
+		{
+			name:     "apply",
+			keys:     []string{"space", "enter"},
+			selected: []string{"/app/00"},
+			applied:  []string{"/app/00"},
+		},
+		{
+			name: "apply nothing",
+			keys: []string{"enter"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var applied []string
+			screen := NewChooseLogsScreen(testLogGroups(t, NewFakeClient(), 25), nil, func(logs []*LogGroup) error {
+				for _, log := range logs {
+					applied = append(applied, log.Name())
+				}
+				return nil
+			})
+			screen.Resize(context.Background(), 13, 80)
+			pressKeys(t, screen, tt.keys...)
+
+			if screen.index != tt.index || screen.offset != tt.offset {
+				t.Errorf("index, offset = %d, %d, want %d, %d", screen.index, screen.offset, tt.index, tt.offset)
+			}
+			selected := []string{}
+			for _, log := range screen.selected {
+				selected = append(selected, log.Name())
+			}
+			if !slices.Equal(selected, append([]string{}, tt.selected...)) {
+				t.Errorf("selected = %v, want %v", selected, tt.selected)
+			}
+			if tt.filtered != 0 && len(screen.filtered) != tt.filtered {
+				t.Errorf("filtered %d log groups, want %d", len(screen.filtered), tt.filtered)
+			}
+			if !slices.Equal(applied, tt.applied) {
+				t.Errorf("applied %v, want %v", applied, tt.applied)
+			}
+		})
+	}
+}
+
+func TestDisplayLogScreenHandleKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  []string
+		index int
+		// pages are added to index in pages of the focused pane.
+		pages int
+		live  bool
+		focus int
+	}{
+		{
+			name:  "live",
+			index: -1,
+			live:  true,
+		},
+		{
+			name:  "down",
+			keys:  repeat("j", 3),
+			index: 2,
+		},
+		{
+			name:  "up stops at the top",
+			keys:  []string{"j", "k", "k"},
+			index: 0,
+		},
+		{
+			name:  "bottom",
+			keys:  []string{"G"},
+			index: 49,
+		},
+		{
+			name: "top",
+			keys: []string{"G", "g", "g"},
+		},
+		{
+			name:  "page down",
+			keys:  []string{"j", "J"},
+			pages: 1,
+		},
+		{
+			name:  "page up",
+			keys:  []string{"G", "pgup"},
+			index: 49,
+			pages: -1,
+		},
+		{
+			name:  "live again",
+			keys:  []string{"j", ","},
+			index: 0,
+			live:  true,
+		},
+		{
+			name:  "next group",
+			keys:  []string{"l"},
+			index: -1,
+			live:  true,
+			focus: 1,
+		},
+		{
+			name:  "previous group wraps",
+			keys:  []string{"h"},
+			index: -1,
+			live:  true,
+			focus: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := testLogGroups(t, NewFakeClient(), 2)
+			screen := NewDisplayLogScreen(logs, func([]*LogGroup) {})
+			for _, log := range logs {
+				arn := log.ARN()
+				for _, evt := range testEvents(50) {
+					screen.appendEvent(arn, evt)
+				}
+				screen.index[arn] = -1
+				screen.live[arn] = true
+			}
+			screen.Resize(context.Background(), 24, 80)
+			pressKeys(t, screen, tt.keys...)
+
+			if screen.log != logs[tt.focus] {
+				t.Fatalf("focused %s, want %s", screen.log.Name(), logs[tt.focus].Name())
+			}
+			arn := screen.log.ARN()
+			want := tt.index + tt.pages*(screen.listRows(arn)-1)
+			if screen.index[arn] != want {
+				t.Errorf("index = %d, want %d", screen.index[arn], want)
+			}
+			if offset := screen.offset[arn]; want >= 0 && (want < offset || want >= offset+screen.listRows(arn)) {
+				t.Errorf("index %d is not shown from offset %d", want, offset)
+			}
+			if screen.live[arn] != tt.live {
+				t.Errorf("live = %v, want %v", screen.live[arn], tt.live)
+			}
+		})
+	}
+}
+
+func messages(events []*LogEvent) []string {
+	msgs := make([]string, len(events))
+	for i, evt := range events {
+		msgs[i] = evt.Message()
+		for _, folded := range evt.folded {
+			msgs[i] += "|" + folded.Message()
+		}
+	}
+	return msgs
+}
+
+func TestDisplayLogScreenBuffer(t *testing.T) {
+	trace := func(at time.Duration, lines int) []*LogEvent {
+		events := []*LogEvent{NewLogEvent(testEvent(at, "stream", "java.lang.IllegalStateException: boom"))}
+		for i := range lines {
+			events = append(events, NewLogEvent(testEvent(at, "stream", fmt.Sprintf("\tat Frame%d", i))))
+		}
+		return events
+	}
+
+	tests := []struct {
+		name   string
+		events []*LogEvent
+		fold   bool
+		lines  int
+		first  string
+	}{
+		{
+			name:   "below the limit",
+			events: testEvents(10),
+			lines:  10,
+			first:  "event 0",
+		},
+		{
+			name:   "oldest events dropped",
+			events: testEvents(MaxEvents + 5),
+			lines:  MaxEvents,
+			first:  "event 5",
+		},
+		{
+			name:   "folded lines counted",
+			events: append(trace(0, 9), testEvents(MaxEvents)[1:]...),
+			fold:   true,
+			lines:  MaxEvents - 1,
+			first:  "event 1",
+		},
+		{
+			name:   "folded lines counted unfolded",
+			events: append(trace(0, 9), testEvents(MaxEvents)[1:]...),
+			lines:  MaxEvents,
+			first:  "at Frame8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := testLogGroups(t, NewFakeClient(), 1)
+			arn := logs[0].ARN()
+			screen := NewDisplayLogScreen(logs, func([]*LogGroup) {})
+			screen.fold = tt.fold
+			for _, evt := range tt.events {
+				screen.appendEvent(arn, evt)
+				screen.trimBuffer(arn)
+			}
+			events := screen.buffers[arn]
+			if n := countLines(events); n != tt.lines {
+				t.Errorf("buffered %d lines, want %d", n, tt.lines)
+			}
+			if events[0].Message() != tt.first {
+				t.Errorf("first event = %q, want %q", events[0].Message(), tt.first)
+			}
+		})
+	}
+}
+
+func TestLoadingScreenRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles int
+		scroll   bool
+	}{
+		{name: "fits", profiles: 5},
+		{name: "overflows", profiles: 6, scroll: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := NewLoadProgress(nil)
+			for i := range tt.profiles {
+				progress.Add(fmt.Sprintf("profile-%d", i))
+			}
+			screen := NewLoadingScreen(progress, func() {})
+			vt := NewVirtualTerminal(8, 80)
+			if err := screen.Render(context.Background(), vt); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(vt.Line(1), "scroll"); got != tt.scroll {
+				t.Errorf("scroll hint shown: %v, want %v", got, tt.scroll)
+			}
+			// The title, the hints and a blank line take three rows, and
+			// the profiles fill the rest.
+			for row := 3; row < 8; row++ {
+				if want := fmt.Sprintf("profile-%d", row-3); !strings.Contains(vt.Line(row), want) {
+					t.Errorf("row %d: %q, want %s", row, vt.Line(row), want)
+				}
+			}
+		})
+	}
+}
+
+func TestDisplayLogScreenLiveTail(t *testing.T) {
+	client := NewFakeClient(fakeLogGroups("111111111111", "us-east-1", 1)...)
+	logs := testLogGroups(t, client, 1)
+	arn := logs[0].ARN()
+	screen := NewDisplayLogScreen(logs, func([]*LogGroup) {})
+	redraws := make(chan struct{}, 1)
+	screen.redraw = func() {
+		select {
+		case redraws <- struct{}{}:
+		default:
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	screen.Init(ctx)
+
+	buffered := func() int {
+		screen.rw.RLock()
+		defer screen.rw.RUnlock()
+		return countLines(screen.buffers[arn])
+	}
+	wait := func(cond func() bool) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for !cond() {
+			select {
+			case <-redraws:
+			case <-timeout:
+				t.Fatal("timed out")
+			}
+		}
+	}
+	wait(func() bool { return client.LiveTails() == 1 })
+
+	events := make([]types.LiveTailSessionLogEvent, MaxEvents+10)
+	for i := range events {
+		events[i] = testEvent(time.Duration(i)*time.Second, "stream", fmt.Sprintf("event %d", i))
+	}
+	client.Emit(arn, events[:10]...)
+	wait(func() bool { return buffered() == 10 })
+	client.Emit(arn, events[10:]...)
+	wait(func() bool { return buffered() == MaxEvents })
+
+	screen.rw.RLock()
+	first := screen.buffers[arn][0].Message()
+	screen.rw.RUnlock()
+	if first != "event 10" {
+		t.Errorf("first event = %q, want %q", first, "event 10")
+	}
+}
+
+func TestDisplayLogScreenMerge(t *testing.T) {
+	event := func(at time.Duration, msg string) *LogEvent {
+		return NewLogEvent(testEvent(at, "stream", msg))
+	}
+
+	tests := []struct {
+		name     string
+		buffered []*LogEvent
+		merged   []*LogEvent
+		want     []string
+	}{
+		{
+			name:   "into an empty buffer",
+			merged: []*LogEvent{event(2*time.Second, "b"), event(time.Second, "a")},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:     "duplicates dropped",
+			buffered: []*LogEvent{event(time.Second, "a"), event(3*time.Second, "c")},
+			merged:   []*LogEvent{event(time.Second, "a"), event(2*time.Second, "b"), event(3*time.Second, "c")},
+			want:     []string{"a", "b", "c"},
+		},
+		{
+			name:     "same time, different message",
+			buffered: []*LogEvent{event(time.Second, "a")},
+			merged:   []*LogEvent{event(time.Second, "a2")},
+			want:     []string{"a", "a2"},
+		},
+		{
+			name:     "folded lines not duplicated",
+			buffered: []*LogEvent{event(time.Second, "java.lang.IllegalStateException: boom"), event(time.Second, "\tat Frame")},
+			merged:   []*LogEvent{event(-time.Minute, "start"), event(time.Second, "java.lang.IllegalStateException: boom"), event(time.Second, "\tat Frame")},
+			want:     []string{"start", "java.lang.IllegalStateException: boom|at Frame"},
+		},
+		{
+			name:     "older events beyond the limit dropped",
+			buffered: testEvents(MaxEvents)[1:],
+			merged:   []*LogEvent{event(-2*time.Second, "older"), event(-time.Second, "old")},
+			want:     append([]string{"old"}, messages(testEvents(MaxEvents)[1:])...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := testLogGroups(t, NewFakeClient(), 1)
+			arn := logs[0].ARN()
+			screen := NewDisplayLogScreen(logs, func([]*LogGroup) {})
+			for _, evt := range tt.buffered {
+				screen.appendEvent(arn, evt)
+			}
+			screen.merge(arn, tt.merged)
+			if got := messages(screen.buffers[arn]); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// checkGolden compares got with testdata/name.golden, or writes it there with
+// -update.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs (run go test -update to accept)\ngot\n%s\nwant\n%s", path, got, want)
+	}
+}
+
+func TestScreenSnapshots(t *testing.T) {
+	choose := func(t *testing.T) Screen {
+		return NewChooseLogsScreen(testLogGroups(t, NewFakeClient(), 25), nil, func([]*LogGroup) error { return nil })
+	}
+	display := func(n int) func(t *testing.T) Screen {
+		return func(t *testing.T) Screen {
+			logs := testLogGroups(t, NewFakeClient(), n)
+			screen := NewDisplayLogScreen(logs, func([]*LogGroup) {})
+			for i, log := range logs {
+				arn := log.ARN()
+				for j := range 40 {
+					msg := fmt.Sprintf("INFO request %d of %s handled", j, log.Name())
+					switch {
+					case j%10 == 7:
+						msg = fmt.Sprintf("ERROR request %d of %s failed: timeout", j, log.Name())
+					case j == 35:
+						msg = "WARN " + strings.Repeat("a long message ", 8)
+					}
+					screen.appendEvent(arn, NewLogEvent(testEvent(time.Duration(j*(i+1))*time.Second, "stream", msg)))
+				}
+				screen.index[arn] = -1
+				screen.live[arn] = true
+			}
+			return screen
+		}
+	}
+
+	tests := []struct {
+		name   string
+		screen func(t *testing.T) Screen
+		keys   []string
+	}{
+		{
+			name:   "choose",
+			screen: choose,
+		},
+		{
+			name:   "choose_selected",
+			screen: choose,
+			keys:   []string{"j", "space", "j", "j", "space"},
+		},
+		{
+			name:   "choose_search",
+			screen: choose,
+			keys:   []string{"/", "a", "p", "p", "/", "1"},
+		},
+		{
+			name:   "choose_filtered",
+			screen: choose,
+			keys:   []string{"/", "a", "p", "p", "/", "1", "enter", "j", "space"},
+		},
+		{
+			name:   "display",
+			screen: display(1),
+		},
+		{
+			name:   "display_cursor",
+			screen: display(1),
+			keys:   []string{"G", "K", "k"},
+		},
+		{
+			name:   "display_wrap",
+			screen: display(1),
+			keys:   []string{"w", "G", "k"},
+		},
+		{
+			name:   "display_detail",
+			screen: display(1),
+			keys:   []string{"g", "g", "space"},
+		},
+		{
+			name:   "display_split",
+			screen: display(2),
+			keys:   []string{"s", "H", "j"},
+		},
+		{
+			name:   "display_groups",
+			screen: display(2),
+			keys:   []string{"l", "H"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			vt := NewVirtualTerminal(24, 80)
+			screen := tt.screen(t)
+			screen.Resize(ctx, 24, 80)
+			if err := screen.Render(ctx, vt); err != nil {
+				t.Fatal(err)
+			}
+			pressKeys(t, screen, tt.keys...)
+			if err := screen.Render(ctx, vt); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.name, vt.Snapshot())
+		})
+	}
+}
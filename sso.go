@@ -0,0 +1,237 @@
+package cwl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssooidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+const (
+	ssoClientName      = "cwl"
+	ssoClientType      = "public"
+	ssoGrantTypeDevice = "urn:ietf:params:oauth:grant-type:device_code"
+	ssoScopeAccess     = "sso:account:access"
+)
+
+// SSOSession is an SSO login shared by one or more profiles. Key names the
+// token cache file: the sso-session name, or the start URL for legacy
+// profiles.
+type SSOSession struct {
+	Key      string
+	Name     string
+	StartURL string
+	Region   string
+	Profiles []string
+}
+
+// LoadSSOSession returns the SSO session profile signs in with, or nil when
+// profile does not use SSO.
+func LoadSSOSession(ctx context.Context, profile string) (*SSOSession, error) {
+	if profile == EnvironmentProfile {
+		return nil, nil
+	}
+	sc, err := config.LoadSharedConfigProfile(ctx, profile, func(o *config.LoadSharedConfigOptions) {
+		o.ConfigFiles = []string{sharedConfigFilename()}
+		o.CredentialsFiles = []string{sharedCredentialsFilename()}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if sc.SSOSession != nil {
+		return &SSOSession{
+			Key:      sc.SSOSession.Name,
+			Name:     sc.SSOSession.Name,
+			StartURL: sc.SSOSession.SSOStartURL,
+			Region:   sc.SSOSession.SSORegion,
+			Profiles: []string{profile},
+		}, nil
+	}
+	if sc.SSOStartURL != "" {
+		return &SSOSession{
+			Key:      sc.SSOStartURL,
+			StartURL: sc.SSOStartURL,
+			Region:   sc.SSORegion,
+			Profiles: []string{profile},
+		}, nil
+	}
+	return nil, nil
+}
+
+// AddSSOSession adds session to sessions, merging its profiles into an
+// existing session with the same key.
+func AddSSOSession(sessions []*SSOSession, session *SSOSession) []*SSOSession {
+	for _, v := range sessions {
+		if v.Key != session.Key {
+			continue
+		}
+		for _, profile := range session.Profiles {
+			if !slices.Contains(v.Profiles, profile) {
+				v.Profiles = append(v.Profiles, profile)
+			}
+		}
+		return sessions
+	}
+	v := *session
+	v.Profiles = slices.Clone(session.Profiles)
+	return append(sessions, &v)
+}
+
+// SSOLogin is the device authorization the user has to approve in a browser.
+type SSOLogin struct {
+	VerificationURI         string
+	VerificationURIComplete string
+	UserCode                string
+	ExpiresAt               time.Time
+}
+
+type ssoCachedToken struct {
+	AccessToken           string `json:"accessToken"`
+	ExpiresAt             string `json:"expiresAt"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+	ClientID              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	Region                string `json:"region,omitempty"`
+	StartURL              string `json:"startUrl,omitempty"`
+}
+
+// Login runs the device authorization flow of s, calling started once the
+// user code is available, and stores the token in the SSO token cache used
+// by the AWS CLI and SDKs.
+func (s *SSOSession) Login(ctx context.Context, started func(SSOLogin)) error {
+	client := ssooidc.New(ssooidc.Options{Region: s.Region})
+
+	register := &ssooidc.RegisterClientInput{
+		ClientName: aws.String(ssoClientName),
+		ClientType: aws.String(ssoClientType),
+	}
+	if s.Name != "" {
+		register.Scopes = []string{ssoScopeAccess}
+	}
+	reg, err := client.RegisterClient(ctx, register)
+	if err != nil {
+		return err
+	}
+
+	auth, err := client.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     reg.ClientId,
+		ClientSecret: reg.ClientSecret,
+		StartUrl:     aws.String(s.StartURL),
+	})
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	started(SSOLogin{
+		VerificationURI:         aws.ToString(auth.VerificationUri),
+		VerificationURIComplete: aws.ToString(auth.VerificationUriComplete),
+		UserCode:                aws.ToString(auth.UserCode),
+		ExpiresAt:               expiresAt,
+	})
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+
+		token, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     reg.ClientId,
+			ClientSecret: reg.ClientSecret,
+			DeviceCode:   auth.DeviceCode,
+			GrantType:    aws.String(ssoGrantTypeDevice),
+		})
+		var pending *ssooidctypes.AuthorizationPendingException
+		var slowDown *ssooidctypes.SlowDownException
+		switch {
+		case errors.As(err, &pending):
+			continue
+		case errors.As(err, &slowDown):
+			interval += 5 * time.Second
+			continue
+		case err != nil:
+			return err
+		}
+
+		return s.storeToken(ssoCachedToken{
+			AccessToken:           aws.ToString(token.AccessToken),
+			ExpiresAt:             time.Now().Add(time.Duration(token.ExpiresIn) * time.Second).UTC().Format(time.RFC3339),
+			RefreshToken:          aws.ToString(token.RefreshToken),
+			ClientID:              aws.ToString(reg.ClientId),
+			ClientSecret:          aws.ToString(reg.ClientSecret),
+			RegistrationExpiresAt: time.Unix(reg.ClientSecretExpiresAt, 0).UTC().Format(time.RFC3339),
+			Region:                s.Region,
+			StartURL:              s.StartURL,
+		})
+	}
+}
+
+// loginRequired reports whether err, the error of loading the credentials of
+// a profile of s, is fixed by signing in again: the SSO token of s is
+// missing, expired or rejected.
+func (s *SSOSession) loginRequired(err error) bool {
+	var invalid *ssocreds.InvalidTokenError
+	var unauthorized *ssotypes.UnauthorizedException
+	var expired *ssooidctypes.ExpiredTokenException
+	var grant *ssooidctypes.InvalidGrantException
+	if errors.As(err, &invalid) || errors.As(err, &unauthorized) || errors.As(err, &expired) || errors.As(err, &grant) {
+		return true
+	}
+	return s.tokenExpired()
+}
+
+// tokenExpired reports whether the SSO token cache has no token of s, or one
+// that has expired.
+func (s *SSOSession) tokenExpired() bool {
+	path, err := ssocreds.StandardCachedTokenFilepath(s.Key)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	var token ssoCachedToken
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return true
+	}
+	expiresAt, err := time.Parse(time.RFC3339, token.ExpiresAt)
+	return err != nil || time.Now().After(expiresAt)
+}
+
+func (s *SSOSession) storeToken(token ssoCachedToken) error {
+	path, err := ssocreds.StandardCachedTokenFilepath(s.Key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(token)
+}
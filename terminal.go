@@ -0,0 +1,91 @@
+package cwl
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Terminal is what screens render into and App reads input from. TTY is the
+// real terminal, and VirtualTerminal an in-memory one.
+type Terminal interface {
+	Open() error
+	Close() error
+	Opened() bool
+	Rune() (rune, error)
+	Read(p []byte) (n int, err error)
+	Write(p []byte) (n int, err error)
+	WriteString(s string, args ...any) error
+	Size() (int, int, int, int, error)
+	Resized() <-chan struct{}
+	Clear() error
+	NextLine(n int) error
+	EnableMouse() error
+	DisableMouse() error
+}
+
+// Recorder is a Terminal that copies everything written to the terminal it
+// wraps to w, so a session can be replayed with cat.
+type Recorder struct {
+	Terminal
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewRecorder(t Terminal, w io.Writer) *Recorder {
+	return &Recorder{
+		Terminal: t,
+		w:        w,
+	}
+}
+
+func (r *Recorder) record(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Write(p)
+}
+
+func (r *Recorder) Open() error {
+	// Writes of Open go straight to the wrapped terminal, so record the modes
+	// it enables to make the recording replay in the alternate screen too.
+	r.record([]byte(ScreenEnableAlt + CursorHide + MouseEnable + PasteEnable))
+	return r.Terminal.Open()
+}
+
+func (r *Recorder) Close() error {
+	r.record([]byte(ScreenDisableAlt + CursorShow + MouseDisable + PasteDisable))
+	return r.Terminal.Close()
+}
+
+func (r *Recorder) EnableMouse() error {
+	r.record([]byte(MouseEnable))
+	return r.Terminal.EnableMouse()
+}
+
+func (r *Recorder) DisableMouse() error {
+	r.record([]byte(MouseDisable))
+	return r.Terminal.DisableMouse()
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.record(p)
+	return r.Terminal.Write(p)
+}
+
+func (r *Recorder) WriteString(s string, args ...any) error {
+	s = fmt.Sprintf(s, args...)
+	r.record([]byte(s))
+	return r.Terminal.WriteString("%s", s)
+}
+
+func (r *Recorder) Clear() error {
+	r.record([]byte(ScreenClearAll + CursorHome))
+	return r.Terminal.Clear()
+}
+
+func (r *Recorder) NextLine(n int) error {
+	for i := 0; i < max(n, 1); i++ {
+		r.record([]byte(CursorNextLine))
+	}
+	return r.Terminal.NextLine(n)
+}
@@ -0,0 +1,354 @@
+package cwl
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Theme is the set of styles screens draw with.
+type Theme struct {
+	Title     Style
+	Header    Style
+	Timestamp Style
+	Message   Style
+	Selected  Style
+	Pending   Style
+	Done      Style
+	Failed    Style
+	Error     Style
+	Warn      Style
+	Info      Style
+	Debug     Style
+
+	highlighter *Highlighter
+}
+
+// Themes are the built-in themes selectable with the theme setting.
+var Themes = map[string]Theme{
+	"dark": {
+		Title:     Style{Bold: true},
+		Header:    Style{FG: "32"},
+		Timestamp: Style{FG: "32"},
+		Message:   Style{FG: "33"},
+		Selected:  Style{Reverse: true},
+		Pending:   Style{FG: "33"},
+		Done:      Style{FG: "32"},
+		Failed:    Style{FG: "31"},
+		Error:     Style{FG: "31"},
+		Warn:      Style{FG: "35"},
+		Info:      Style{FG: "32"},
+		Debug:     Style{FG: "34"},
+	},
+	"light": {
+		Title:     Style{Bold: true},
+		Header:    Style{FG: "34"},
+		Timestamp: Style{FG: "38;5;25"},
+		Message:   Style{},
+		Selected:  Style{Reverse: true},
+		Pending:   Style{FG: "38;5;130"},
+		Done:      Style{FG: "38;5;28"},
+		Failed:    Style{FG: "38;5;160"},
+		Error:     Style{FG: "38;5;160", Bold: true},
+		Warn:      Style{FG: "38;5;130"},
+		Info:      Style{FG: "38;5;28"},
+		Debug:     Style{FG: "38;5;25"},
+	},
+	"solarized": {
+		Title:     Style{FG: "38;2;147;161;161", Bold: true},
+		Header:    Style{FG: "38;2;133;153;0"},
+		Timestamp: Style{FG: "38;2;88;110;117"},
+		Message:   Style{FG: "38;2;131;148;150"},
+		Selected:  Style{Reverse: true},
+		Pending:   Style{FG: "38;2;181;137;0"},
+		Done:      Style{FG: "38;2;133;153;0"},
+		Failed:    Style{FG: "38;2;220;50;47"},
+		Error:     Style{FG: "38;2;220;50;47", Bold: true},
+		Warn:      Style{FG: "38;2;203;75;22"},
+		Info:      Style{FG: "38;2;42;161;152"},
+		Debug:     Style{FG: "38;2;38;139;210"},
+	},
+	"mono": {
+		Title:    Style{Bold: true},
+		Selected: Style{Reverse: true},
+		Failed:   Style{Bold: true},
+		Error:    Style{Bold: true},
+		Warn:     Style{Underline: true},
+	},
+}
+
+// DefaultTheme is the dark theme with the level highlights only.
+var DefaultTheme = must(NewTheme(Themes["dark"]))
+
+// NewTheme returns t with the level highlights compiled.
+func NewTheme(t Theme) (*Theme, error) {
+	h, err := NewHighlighter(nil, &t)
+	if err != nil {
+		return nil, err
+	}
+	t.highlighter = h
+	return &t, nil
+}
+
+// LoadTheme returns the theme named in the config, with the highlight rules
+// compiled. NO_COLOR, when set, removes every color and keeps only bold,
+// underline and reverse.
+func (c *Config) LoadTheme() (*Theme, error) {
+	name := c.Theme
+	if name == "" {
+		name = "dark"
+	}
+	t, ok := Themes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme: %s", name)
+	}
+
+	h, err := NewHighlighter(c.Highlights, &t)
+	if err != nil {
+		return nil, err
+	}
+	t.highlighter = h
+	if os.Getenv("NO_COLOR") != "" {
+		t = t.monochrome()
+	}
+	return &t, nil
+}
+
+func (t Theme) monochrome() Theme {
+	for _, s := range []*Style{&t.Title, &t.Header, &t.Timestamp, &t.Message, &t.Selected, &t.Pending, &t.Done, &t.Failed, &t.Error, &t.Warn, &t.Info, &t.Debug} {
+		s.FG, s.BG = "", ""
+	}
+	h := &Highlighter{}
+	for _, rule := range t.highlighter.rules {
+		rule.style.FG, rule.style.BG = "", ""
+		h.rules = append(h.rules, rule)
+	}
+	t.highlighter = h
+	return t
+}
+
+// Highlight styles the parts of a log message matched by the level keywords
+// and highlight rules over base.
+func (t *Theme) Highlight(message string, base Style) string {
+	return t.highlighter.Apply(message, base)
+}
+
+// Paint returns text in style s, resetting the style after it.
+func (s Style) Paint(text string) string {
+	return s.sgr() + text + ScreenReset
+}
+
+// Merge returns s with the attributes set in o laid over it.
+func (s Style) Merge(o Style) Style {
+	if o.FG != "" {
+		s.FG = o.FG
+	}
+	if o.BG != "" {
+		s.BG = o.BG
+	}
+	s.Bold = s.Bold || o.Bold
+	s.Underline = s.Underline || o.Underline
+	s.Reverse = s.Reverse || o.Reverse
+	return s
+}
+
+// HighlightRule styles the parts of log messages matching Pattern. Colors
+// are names such as "red" or "bright-red", 256-color numbers such as "208",
+// or "#rrggbb".
+type HighlightRule struct {
+	Pattern   string `json:"pattern"`
+	FG        string `json:"fg"`
+	BG        string `json:"bg"`
+	Bold      bool   `json:"bold"`
+	Underline bool   `json:"underline"`
+}
+
+type highlight struct {
+	re    *regexp.Regexp
+	style Style
+}
+
+// Highlighter applies highlight rules in order, so a later rule wins where
+// matches overlap.
+type Highlighter struct {
+	rules []highlight
+}
+
+// NewHighlighter compiles the level keywords of t followed by rules.
+func NewHighlighter(rules []HighlightRule, t *Theme) (*Highlighter, error) {
+	h := &Highlighter{}
+	for _, level := range []struct {
+		pattern string
+		style   Style
+	}{
+		{`INFO`, t.Info},
+		{`DEBUG`, t.Debug},
+		{`WARN`, t.Warn},
+		{`ERROR`, t.Error},
+	} {
+		if level.style == (Style{}) {
+			continue
+		}
+		h.rules = append(h.rules, highlight{re: regexp.MustCompile(level.pattern), style: level.style})
+	}
+
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid highlight pattern %q: %w", rule.Pattern, err)
+		}
+		fg, err := parseColor(rule.FG, false)
+		if err != nil {
+			return nil, err
+		}
+		bg, err := parseColor(rule.BG, true)
+		if err != nil {
+			return nil, err
+		}
+		h.rules = append(h.rules, highlight{
+			re:    re,
+			style: Style{FG: fg, BG: bg, Bold: rule.Bold, Underline: rule.Underline},
+		})
+	}
+	return h, nil
+}
+
+// Apply returns s in style base with the matches of the rules styled over
+// it. Escape sequences already in s are kept and not matched against.
+func (h *Highlighter) Apply(s string, base Style) string {
+	plain := strings.Builder{}
+	type escape struct {
+		pos int
+		seq string
+	}
+	escapes := []escape{}
+	segments(s, func(segment string, _ int) bool {
+		if segment[0] == 0x1b {
+			escapes = append(escapes, escape{pos: plain.Len(), seq: segment})
+		} else {
+			plain.WriteString(segment)
+		}
+		return true
+	})
+	text := plain.String()
+
+	styles := make([]int, len(text))
+	for i := range styles {
+		styles[i] = -1
+	}
+	for i, rule := range h.rules {
+		for _, m := range rule.re.FindAllStringIndex(text, -1) {
+			for j := m[0]; j < m[1]; j++ {
+				styles[j] = i
+			}
+		}
+	}
+
+	b := strings.Builder{}
+	b.WriteString(base.sgr())
+	current := -1
+	style := func() Style {
+		if current < 0 {
+			return base
+		}
+		return base.Merge(h.rules[current].style)
+	}
+	for j := 0; j < len(text); j++ {
+		for len(escapes) > 0 && escapes[0].pos == j {
+			// A reset in the message would clear base for the rest of the
+			// line, so the style is written again in its place, followed by
+			// what the sequence sets after the reset.
+			if params, ok := sgrAfterReset(escapes[0].seq); ok {
+				b.WriteString(style().sgr())
+				if len(params) > 0 {
+					b.WriteString("\x1b[" + strings.Join(params, ";") + "m")
+				}
+			} else {
+				b.WriteString(escapes[0].seq)
+			}
+			escapes = escapes[1:]
+		}
+		if styles[j] != current {
+			current = styles[j]
+			b.WriteString(style().sgr())
+		}
+		b.WriteByte(text[j])
+	}
+	for _, e := range escapes {
+		b.WriteString(e.seq)
+	}
+	b.WriteString(ScreenReset)
+	return b.String()
+}
+
+// sgrAfterReset reports whether seq is an SGR sequence that resets the
+// style, and returns its parameters after the last reset. The parameters of
+// extended colors are skipped, as their zeros are not resets.
+func sgrAfterReset(seq string) (params []string, ok bool) {
+	if !strings.HasPrefix(seq, "\x1b[") || !strings.HasSuffix(seq, "m") || strings.ContainsAny(seq[2:3], "<=>?") {
+		return nil, false
+	}
+	args := strings.Split(seq[2:len(seq)-1], ";")
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "", "0":
+			params, ok = nil, true
+			continue
+		case "38", "48", "58":
+			n := 0
+			if i+1 < len(args) {
+				switch args[i+1] {
+				case "5":
+					n = 2
+				case "2":
+					n = 4
+				}
+			}
+			params = append(params, args[i:min(i+1+n, len(args))]...)
+			i += n
+			continue
+		}
+		params = append(params, args[i])
+	}
+	return params, ok
+}
+
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// parseColor returns the SGR parameters of a color for the foreground, or
+// the background if bg is set.
+func parseColor(color string, bg bool) (string, error) {
+	base, extended := 30, "38"
+	if bg {
+		base, extended = 40, "48"
+	}
+	name := strings.ToLower(color)
+	switch {
+	case name == "":
+		return "", nil
+	case name == "default":
+		return strconv.Itoa(base + 9), nil
+	case strings.HasPrefix(name, "#") && len(name) == 7:
+		rgb, err := strconv.ParseUint(name[1:], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid color: %s", color)
+		}
+		return fmt.Sprintf("%s;2;%d;%d;%d", extended, rgb>>16, rgb>>8&0xff, rgb&0xff), nil
+	}
+	if n, err := strconv.Atoi(name); err == nil && 0 <= n && n <= 255 {
+		return fmt.Sprintf("%s;5;%d", extended, n), nil
+	}
+	bright := strings.HasPrefix(name, "bright-")
+	for i, c := range colorNames {
+		if strings.TrimPrefix(name, "bright-") != c {
+			continue
+		}
+		if bright {
+			return strconv.Itoa(base + 60 + i), nil
+		}
+		return strconv.Itoa(base + i), nil
+	}
+	return "", fmt.Errorf("invalid color: %s", color)
+}
@@ -0,0 +1,60 @@
+package cwl
+
+import "testing"
+
+func TestHighlighterApply(t *testing.T) {
+	base := Style{FG: "37", BG: "44"}
+	red := Style{FG: "31", BG: "44"}
+	bold := Style{FG: "37", BG: "44", Bold: true}
+	h, err := NewHighlighter([]HighlightRule{{Pattern: "x", Bold: true}}, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		s    string
+		want []Style
+	}{
+		{
+			name: "plain",
+			s:    "ab",
+			want: []Style{base, base},
+		},
+		{
+			name: "reset",
+			s:    "a\x1b[31mb\x1b[0mc",
+			want: []Style{base, red, base},
+		},
+		{
+			name: "empty reset",
+			s:    "\x1b[31ma\x1b[mb",
+			want: []Style{red, base},
+		},
+		{
+			name: "reset and color",
+			s:    "a\x1b[0;31mb",
+			want: []Style{base, red},
+		},
+		{
+			name: "extended color zero is not a reset",
+			s:    "a\x1b[38;5;0mb",
+			want: []Style{base, {FG: "38;5;0", BG: "44"}},
+		},
+		{
+			name: "reset in a match",
+			s:    "xx\x1b[0mxa",
+			want: []Style{bold, bold, bold, base},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(1, 10)
+			vt.Write([]byte(h.Apply(tt.s, base)))
+			for col, want := range tt.want {
+				if got := vt.Cell(0, col).Style; got != want {
+					t.Errorf("col %d: got %+v, want %+v", col, got, want)
+				}
+			}
+		})
+	}
+}
@@ -29,6 +29,10 @@ const (
 	CursorPrevLine   = "\x1b[1F"
 	CursorHide       = "\x1b[?25l"
 	CursorShow       = "\x1b[?25h"
+	MouseEnable      = "\x1b[?1000h\x1b[?1002h\x1b[?1006h"
+	MouseDisable     = "\x1b[?1006l\x1b[?1002l\x1b[?1000l"
+	PasteEnable      = "\x1b[?2004h"
+	PasteDisable     = "\x1b[?2004l"
 )
 
 const (
@@ -36,12 +40,15 @@ const (
 )
 
 type TTY struct {
-	t   *tty.TTY
-	alt bool
+	t       *tty.TTY
+	alt     bool
+	resized chan struct{}
 }
 
 func NewTTY() (*TTY, error) {
-	return &TTY{}, nil
+	return &TTY{
+		resized: make(chan struct{}, 1),
+	}, nil
 }
 
 func (t *TTY) Open() error {
@@ -53,6 +60,17 @@ func (t *TTY) Open() error {
 	t.EnableAlt()
 	t.HideCursor()
 	t.EnableMouse()
+	t.t.Output().WriteString(PasteEnable)
+
+	winch := t.t.SIGWINCH()
+	go func() {
+		for range winch {
+			select {
+			case t.resized <- struct{}{}:
+			default:
+			}
+		}
+	}()
 	return nil
 }
 
@@ -61,11 +79,21 @@ func (t *TTY) Close() error {
 		t.DisableAlt()
 		t.ShowCursor()
 		t.DisableMouse()
+		t.t.Output().WriteString(PasteDisable)
 		t.t.Close()
 	}
 	return nil
 }
 
+func (t *TTY) Opened() bool {
+	return t.t != nil
+}
+
+// Resized receives a value when the terminal window changes size.
+func (t *TTY) Resized() <-chan struct{} {
+	return t.resized
+}
+
 func (t *TTY) Rune() (rune, error) {
 	return t.t.ReadRune()
 }
@@ -124,15 +152,17 @@ func (t *TTY) ClearLine() error {
 	return nil
 }
 
+// EnableMouse turns on reporting of clicks, drags and the wheel in the SGR
+// encoding, which unlike the legacy one works beyond column 223.
 func (t *TTY) EnableMouse() error {
-	if _, err := t.t.Output().WriteString("\x1b[?1000h"); err != nil {
+	if _, err := t.t.Output().WriteString(MouseEnable); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (t *TTY) DisableMouse() error {
-	if _, err := t.t.Output().WriteString("\x1b[?1000l"); err != nil {
+	if _, err := t.t.Output().WriteString(MouseDisable); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,548 @@
+package cwl
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// Style is the SGR state of a cell. FG and BG hold the SGR parameters that
+// set the color, such as "31" or "38;5;208", or "" for the default color.
+type Style struct {
+	FG        string
+	BG        string
+	Bold      bool
+	Underline bool
+	Reverse   bool
+}
+
+// Cell is one column of the screen. Rune is the first rune of the grapheme
+// cluster written there and Rest the remaining ones, such as combining
+// marks. The right half of a wide character is a Cell with Rune 0.
+type Cell struct {
+	Rune  rune
+	Rest  string
+	Style Style
+}
+
+// VirtualTerminal is an in-memory Terminal. It interprets the escape
+// sequences cwl writes into a grid of cells, and serves input queued with
+// Type, so screens can be driven and inspected without a real terminal.
+type VirtualTerminal struct {
+	mu      sync.Mutex
+	rows    int
+	cols    int
+	cells   [][]Cell
+	row     int
+	col     int
+	saved   [2]int
+	style   Style
+	pending []byte
+	opened  bool
+	alt     bool
+	mouse   bool
+	cursor  bool
+
+	input   chan rune
+	closed  chan struct{}
+	once    sync.Once
+	resized chan struct{}
+}
+
+func NewVirtualTerminal(rows, cols int) *VirtualTerminal {
+	t := &VirtualTerminal{
+		rows:    rows,
+		cols:    cols,
+		cursor:  true,
+		input:   make(chan rune, 1024),
+		closed:  make(chan struct{}),
+		resized: make(chan struct{}, 1),
+	}
+	t.cells = t.blank(rows, cols)
+	return t
+}
+
+func (t *VirtualTerminal) blank(rows, cols int) [][]Cell {
+	cells := make([][]Cell, rows)
+	for i := range cells {
+		cells[i] = make([]Cell, cols)
+		for j := range cells[i] {
+			cells[i][j] = Cell{Rune: ' '}
+		}
+	}
+	return cells
+}
+
+func (t *VirtualTerminal) Open() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.opened = true
+	t.alt = true
+	t.mouse = true
+	t.cursor = false
+	return nil
+}
+
+// Close stops input, making Rune return io.EOF once the queue is drained.
+func (t *VirtualTerminal) Close() error {
+	t.mu.Lock()
+	t.opened = false
+	t.alt = false
+	t.mouse = false
+	t.cursor = true
+	t.mu.Unlock()
+	t.once.Do(func() {
+		close(t.closed)
+	})
+	return nil
+}
+
+func (t *VirtualTerminal) Opened() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.opened
+}
+
+// Type queues s as keyboard input, escape sequences included.
+func (t *VirtualTerminal) Type(s string) {
+	for _, r := range s {
+		t.input <- r
+	}
+}
+
+func (t *VirtualTerminal) Rune() (rune, error) {
+	select {
+	case r := <-t.input:
+		return r, nil
+	case <-t.closed:
+		select {
+		case r := <-t.input:
+			return r, nil
+		default:
+			return 0, io.EOF
+		}
+	}
+}
+
+func (t *VirtualTerminal) Read(p []byte) (int, error) {
+	for i := range p {
+		r, err := t.Rune()
+		if err != nil {
+			return i, err
+		}
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func (t *VirtualTerminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, p...)
+	t.parse()
+	return len(p), nil
+}
+
+func (t *VirtualTerminal) WriteString(s string, args ...any) error {
+	_, err := t.Write([]byte(fmt.Sprintf(s, args...)))
+	return err
+}
+
+func (t *VirtualTerminal) Size() (int, int, int, int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rows, t.cols, 0, 0, nil
+}
+
+// Resize changes the size of the terminal, keeping the top left content, and
+// notifies Resized like a window resize would.
+func (t *VirtualTerminal) Resize(rows, cols int) {
+	t.mu.Lock()
+	cells := t.blank(rows, cols)
+	for i := 0; i < min(rows, t.rows); i++ {
+		copy(cells[i], t.cells[i][:min(cols, t.cols)])
+	}
+	t.rows, t.cols, t.cells = rows, cols, cells
+	t.row = min(t.row, rows-1)
+	t.col = min(t.col, cols-1)
+	t.mu.Unlock()
+
+	select {
+	case t.resized <- struct{}{}:
+	default:
+	}
+}
+
+func (t *VirtualTerminal) Resized() <-chan struct{} {
+	return t.resized
+}
+
+func (t *VirtualTerminal) Clear() error {
+	return t.WriteString(ScreenClearAll + CursorHome)
+}
+
+func (t *VirtualTerminal) NextLine(n int) error {
+	return t.WriteString("%s", strings.Repeat(CursorNextLine, max(n, 1)))
+}
+
+func (t *VirtualTerminal) EnableMouse() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mouse = true
+	return nil
+}
+
+func (t *VirtualTerminal) DisableMouse() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mouse = false
+	return nil
+}
+
+func (t *VirtualTerminal) MouseEnabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.mouse
+}
+
+func (t *VirtualTerminal) AltScreen() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.alt
+}
+
+func (t *VirtualTerminal) CursorVisible() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cursor
+}
+
+// Cursor returns the zero-based cursor position.
+func (t *VirtualTerminal) Cursor() (row, col int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.row, t.col
+}
+
+func (t *VirtualTerminal) Cell(row, col int) Cell {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cells[row][col]
+}
+
+// Snapshot returns the screen as text, one line per row with trailing spaces
+// removed, for comparison against golden files.
+func (t *VirtualTerminal) Snapshot() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := strings.Builder{}
+	for _, line := range t.cells {
+		s := strings.Builder{}
+		for _, cell := range line {
+			if cell.Rune != 0 {
+				s.WriteRune(cell.Rune)
+				s.WriteString(cell.Rest)
+			}
+		}
+		b.WriteString(strings.TrimRight(s.String(), " "))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Line returns row as text with the escape sequences that style it, so that
+// it can be written to another terminal.
+func (t *VirtualTerminal) Line(row int) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := strings.Builder{}
+	style := Style{}
+	for _, cell := range t.cells[row] {
+		if cell.Rune == 0 {
+			continue
+		}
+		if cell.Style != style {
+			b.WriteString(cell.Style.sgr())
+			style = cell.Style
+		}
+		b.WriteRune(cell.Rune)
+		b.WriteString(cell.Rest)
+	}
+	b.WriteString(ScreenReset)
+	return b.String()
+}
+
+// parse consumes complete characters and escape sequences from pending and
+// leaves an incomplete trailing one for the next write.
+func (t *VirtualTerminal) parse() {
+	for len(t.pending) > 0 {
+		b := t.pending[0]
+		switch {
+		case b == 0x1b:
+			n := t.escape(t.pending)
+			if n == 0 {
+				return
+			}
+			t.pending = t.pending[n:]
+		case b == '\n':
+			t.lineFeed()
+			t.pending = t.pending[1:]
+		case b == '\r':
+			t.col = 0
+			t.pending = t.pending[1:]
+		case b < 0x20:
+			t.pending = t.pending[1:]
+		default:
+			n := t.text()
+			if n == 0 {
+				return
+			}
+			text := string(t.pending[:n])
+			state := -1
+			for len(text) > 0 {
+				var cluster string
+				var width int
+				cluster, text, width, state = uniseg.FirstGraphemeClusterInString(text, state)
+				t.put(cluster, width)
+			}
+			t.pending = t.pending[n:]
+		}
+	}
+}
+
+// text returns the length of the printable text at the start of pending,
+// without an incomplete character at its end.
+func (t *VirtualTerminal) text() int {
+	n := len(t.pending)
+	for i, b := range t.pending {
+		if b < 0x20 {
+			n = i
+			break
+		}
+	}
+	for i := n - 1; i >= max(n-utf8.UTFMax, 0); i-- {
+		if utf8.RuneStart(t.pending[i]) {
+			if !utf8.FullRune(t.pending[i:n]) {
+				n = i
+			}
+			break
+		}
+	}
+	return n
+}
+
+func (t *VirtualTerminal) put(cluster string, width int) {
+	r, size := utf8.DecodeRuneInString(cluster)
+	if width == 0 {
+		// Zero width characters such as combining marks join the cell before.
+		col := t.col - 1
+		if col > 0 && t.cells[t.row][col].Rune == 0 {
+			col--
+		}
+		if col >= 0 {
+			t.cells[t.row][col].Rest += cluster
+		}
+		return
+	}
+	width = min(width, 2, t.cols)
+	if t.col+width > t.cols {
+		t.col = 0
+		t.lineFeed()
+	}
+
+	line := t.cells[t.row]
+	// Overwriting half of a wide character erases the other half.
+	if line[t.col].Rune == 0 && t.col > 0 {
+		line[t.col-1] = Cell{Rune: ' '}
+	}
+	if end := t.col + width; end < t.cols && line[end].Rune == 0 {
+		line[end] = Cell{Rune: ' '}
+	}
+	line[t.col] = Cell{Rune: r, Rest: cluster[size:], Style: t.style}
+	if width == 2 {
+		line[t.col+1] = Cell{Style: t.style}
+	}
+	t.col += width
+}
+
+func (t *VirtualTerminal) lineFeed() {
+	if t.row < t.rows-1 {
+		t.row++
+		return
+	}
+	t.cells = append(t.cells[1:], t.blank(1, t.cols)[0])
+}
+
+// escape handles the escape sequence at the start of p and returns its
+// length, or 0 if it is incomplete.
+func (t *VirtualTerminal) escape(p []byte) int {
+	if len(p) < 2 {
+		return 0
+	}
+	if p[1] != '[' {
+		return 2
+	}
+	end := -1
+	for i := 2; i < len(p); i++ {
+		if p[i] >= 0x40 && p[i] <= 0x7e {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return 0
+	}
+	t.csi(string(p[2:end]), p[end])
+	return end + 1
+}
+
+func (t *VirtualTerminal) csi(params string, final byte) {
+	if strings.HasPrefix(params, "?") {
+		t.mode(params[1:], final == 'h')
+		return
+	}
+
+	args := []int{}
+	for _, param := range strings.Split(params, ";") {
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			n = 0
+		}
+		args = append(args, n)
+	}
+	arg := func(i, def int) int {
+		if i < len(args) && args[i] != 0 {
+			return args[i]
+		}
+		return def
+	}
+
+	switch final {
+	case 'A':
+		t.row = max(t.row-arg(0, 1), 0)
+	case 'B':
+		t.row = min(t.row+arg(0, 1), t.rows-1)
+	case 'C':
+		t.col = min(t.col+arg(0, 1), t.cols-1)
+	case 'D':
+		t.col = max(t.col-arg(0, 1), 0)
+	case 'E':
+		t.row = min(t.row+arg(0, 1), t.rows-1)
+		t.col = 0
+	case 'F':
+		t.row = max(t.row-arg(0, 1), 0)
+		t.col = 0
+	case 'G':
+		t.col = min(arg(0, 1)-1, t.cols-1)
+	case 'H', 'f':
+		t.row = min(arg(0, 1)-1, t.rows-1)
+		t.col = min(arg(1, 1)-1, t.cols-1)
+	case 'J':
+		t.eraseDisplay(args[0])
+	case 'K':
+		t.eraseLine(args[0])
+	case 'm':
+		t.sgr(params)
+	case 's':
+		t.saved = [2]int{t.row, t.col}
+	case 'u':
+		t.row, t.col = t.saved[0], t.saved[1]
+	}
+}
+
+func (t *VirtualTerminal) mode(params string, set bool) {
+	for _, param := range strings.Split(params, ";") {
+		switch param {
+		case "1049":
+			t.alt = set
+		case "25":
+			t.cursor = set
+		case "1000", "1002", "1003", "1006":
+			t.mouse = set
+		}
+	}
+}
+
+func (t *VirtualTerminal) eraseDisplay(n int) {
+	switch n {
+	case 0:
+		t.eraseLine(0)
+		for i := t.row + 1; i < t.rows; i++ {
+			t.cells[i] = t.blank(1, t.cols)[0]
+		}
+	case 1:
+		t.eraseLine(1)
+		for i := 0; i < t.row; i++ {
+			t.cells[i] = t.blank(1, t.cols)[0]
+		}
+	default:
+		t.cells = t.blank(t.rows, t.cols)
+	}
+}
+
+func (t *VirtualTerminal) eraseLine(n int) {
+	from, to := t.col, t.cols
+	switch n {
+	case 1:
+		from, to = 0, min(t.col+1, t.cols)
+	case 2:
+		from = 0
+	}
+	for i := from; i < to; i++ {
+		t.cells[t.row][i] = Cell{Rune: ' '}
+	}
+}
+
+func (t *VirtualTerminal) sgr(params string) {
+	if params == "" {
+		t.style = Style{}
+		return
+	}
+	parts := strings.Split(params, ";")
+	for i := 0; i < len(parts); i++ {
+		n, _ := strconv.Atoi(parts[i])
+		switch {
+		case n == 0:
+			t.style = Style{}
+		case n == 1:
+			t.style.Bold = true
+		case n == 4:
+			t.style.Underline = true
+		case n == 7:
+			t.style.Reverse = true
+		case n == 22:
+			t.style.Bold = false
+		case n == 24:
+			t.style.Underline = false
+		case n == 27:
+			t.style.Reverse = false
+		case n == 39:
+			t.style.FG = ""
+		case n == 49:
+			t.style.BG = ""
+		case n == 38 || n == 48:
+			// 38;5;n and 38;2;r;g;b take the following parameters.
+			size := 2
+			if i+1 < len(parts) && parts[i+1] == "2" {
+				size = 4
+			}
+			end := min(i+1+size, len(parts))
+			color := strings.Join(parts[i:end], ";")
+			if n == 38 {
+				t.style.FG = color
+			} else {
+				t.style.BG = color
+			}
+			i = end - 1
+		case 30 <= n && n <= 37 || 90 <= n && n <= 97:
+			t.style.FG = parts[i]
+		case 40 <= n && n <= 47 || 100 <= n && n <= 107:
+			t.style.BG = parts[i]
+		}
+	}
+}
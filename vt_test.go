@@ -0,0 +1,464 @@
+package cwl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// screenLines returns the snapshot of a terminal of len(lines) rows showing
+// lines.
+func screenLines(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestVirtualTerminalCSI(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+		row    int
+		col    int
+	}{
+		{
+			name:   "text",
+			writes: []string{"ab"},
+			want:   screenLines("ab", "", "", ""),
+			col:    2,
+		},
+		{
+			name:   "cursor position",
+			writes: []string{"\x1b[2;3Hx"},
+			want:   screenLines("", "  x", "", ""),
+			row:    1,
+			col:    3,
+		},
+		{
+			name:   "cursor position defaults to the top left",
+			writes: []string{"\x1b[3;3H\x1b[Hx"},
+			want:   screenLines("x", "", "", ""),
+			col:    1,
+		},
+		{
+			name:   "cursor position clamped",
+			writes: []string{"\x1b[99;99Hx"},
+			want:   screenLines("", "", "", "         x"),
+			row:    3,
+			col:    10,
+		},
+		{
+			name:   "relative moves",
+			writes: []string{"\x1b[3B\x1b[5Cx\x1b[2A\x1b[2Dy"},
+			want:   screenLines("", "    y", "", "     x"),
+			row:    1,
+			col:    5,
+		},
+		{
+			name:   "relative moves clamped",
+			writes: []string{"\x1b[9A\x1b[9Dx\x1b[9B\x1b[99Cy"},
+			want:   screenLines("x", "", "", "         y"),
+			row:    3,
+			col:    10,
+		},
+		{
+			name:   "column",
+			writes: []string{"abc\x1b[2Gx"},
+			want:   screenLines("axc", "", "", ""),
+			col:    2,
+		},
+		{
+			name:   "next and previous line",
+			writes: []string{"ab\x1b[Ec\x1b[2Fd"},
+			want:   screenLines("db", "c", "", ""),
+			col:    1,
+		},
+		{
+			name:   "erase to the end of the line",
+			writes: []string{"abcdef\x1b[1;3H\x1b[K"},
+			want:   screenLines("ab", "", "", ""),
+			col:    2,
+		},
+		{
+			name:   "erase to the start of the line",
+			writes: []string{"abcdef\x1b[1;3H\x1b[1K"},
+			want:   screenLines("   def", "", "", ""),
+			col:    2,
+		},
+		{
+			name:   "erase the line",
+			writes: []string{"abcdef\x1b[1;3H\x1b[2K"},
+			want:   screenLines("", "", "", ""),
+			col:    2,
+		},
+		{
+			name:   "erase below",
+			writes: []string{"aa\r\nbb\r\ncc\x1b[2;2H\x1b[J"},
+			want:   screenLines("aa", "b", "", ""),
+			row:    1,
+			col:    1,
+		},
+		{
+			name:   "erase above",
+			writes: []string{"aa\r\nbb\r\ncc\x1b[2;2H\x1b[1J"},
+			want:   screenLines("", "", "cc", ""),
+			row:    1,
+			col:    1,
+		},
+		{
+			name:   "erase the display",
+			writes: []string{"aa\r\nbb\x1b[2J"},
+			want:   screenLines("", "", "", ""),
+			row:    1,
+			col:    2,
+		},
+		{
+			name:   "save and restore",
+			writes: []string{"\x1b[2;2H\x1b[s\x1b[4;4H\x1b[ux"},
+			want:   screenLines("", " x", "", ""),
+			row:    1,
+			col:    2,
+		},
+		{
+			name:   "scroll",
+			writes: []string{"1\r\n2\r\n3\r\n4\r\n5"},
+			want:   screenLines("2", "3", "4", "5"),
+			row:    3,
+			col:    1,
+		},
+		{
+			name:   "wrap and scroll",
+			writes: []string{"\x1b[4;1H0123456789ab"},
+			want:   screenLines("", "", "0123456789", "ab"),
+			row:    3,
+			col:    2,
+		},
+		{
+			name:   "control characters ignored",
+			writes: []string{"a\x07\tb"},
+			want:   screenLines("ab", "", "", ""),
+			col:    2,
+		},
+		{
+			name:   "other escapes ignored",
+			writes: []string{"a\x1b7b"},
+			want:   screenLines("ab", "", "", ""),
+			col:    2,
+		},
+		{
+			name:   "sequence split across writes",
+			writes: []string{"\x1b", "[2", ";3", "Hx"},
+			want:   screenLines("", "  x", "", ""),
+			row:    1,
+			col:    3,
+		},
+		{
+			name:   "character split across writes",
+			writes: []string{"a\xe3", "\x81", "\x82b"},
+			want:   screenLines("aあb", "", "", ""),
+			col:    4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(4, 10)
+			for _, s := range tt.writes {
+				if _, err := vt.Write([]byte(s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := vt.Snapshot(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			if row, col := vt.Cursor(); row != tt.row || col != tt.col {
+				t.Errorf("cursor = %d, %d, want %d, %d", row, col, tt.row, tt.col)
+			}
+		})
+	}
+}
+
+func TestVirtualTerminalModes(t *testing.T) {
+	vt := NewVirtualTerminal(4, 10)
+	vt.WriteString(ScreenEnableAlt + CursorHide + MouseEnable)
+	if !vt.AltScreen() || vt.CursorVisible() || !vt.MouseEnabled() {
+		t.Errorf("alt, cursor, mouse = %v, %v, %v after enabling", vt.AltScreen(), vt.CursorVisible(), vt.MouseEnabled())
+	}
+	vt.WriteString(ScreenDisableAlt + CursorShow + MouseDisable)
+	if vt.AltScreen() || !vt.CursorVisible() || vt.MouseEnabled() {
+		t.Errorf("alt, cursor, mouse = %v, %v, %v after disabling", vt.AltScreen(), vt.CursorVisible(), vt.MouseEnabled())
+	}
+}
+
+func TestVirtualTerminalSGR(t *testing.T) {
+	tests := []struct {
+		name string
+		sgr  string
+		want Style
+	}{
+		{
+			name: "bold",
+			sgr:  "\x1b[1m",
+			want: Style{Bold: true},
+		},
+		{
+			name: "several attributes",
+			sgr:  "\x1b[1;4;7m",
+			want: Style{Bold: true, Underline: true, Reverse: true},
+		},
+		{
+			name: "attributes off",
+			sgr:  "\x1b[1;4;7m\x1b[22;24m",
+			want: Style{Reverse: true},
+		},
+		{
+			name: "reset",
+			sgr:  "\x1b[1;31m\x1b[0m",
+			want: Style{},
+		},
+		{
+			name: "reset without parameters",
+			sgr:  "\x1b[1;31m\x1b[m",
+			want: Style{},
+		},
+		{
+			name: "reset among parameters",
+			sgr:  "\x1b[1;31;0;4m",
+			want: Style{Underline: true},
+		},
+		{
+			name: "colors",
+			sgr:  "\x1b[31;44m",
+			want: Style{FG: "31", BG: "44"},
+		},
+		{
+			name: "bright colors",
+			sgr:  "\x1b[91;104m",
+			want: Style{FG: "91", BG: "104"},
+		},
+		{
+			name: "256 colors",
+			sgr:  "\x1b[38;5;208;48;5;17m",
+			want: Style{FG: "38;5;208", BG: "48;5;17"},
+		},
+		{
+			name: "256 colors followed by an attribute",
+			sgr:  "\x1b[38;5;1;1m",
+			want: Style{FG: "38;5;1", Bold: true},
+		},
+		{
+			name: "true colors",
+			sgr:  "\x1b[38;2;1;2;3;48;2;4;5;6m",
+			want: Style{FG: "38;2;1;2;3", BG: "48;2;4;5;6"},
+		},
+		{
+			name: "true color followed by an attribute",
+			sgr:  "\x1b[38;2;10;20;30;4m",
+			want: Style{FG: "38;2;10;20;30", Underline: true},
+		},
+		{
+			name: "truncated color",
+			sgr:  "\x1b[38;5m",
+			want: Style{FG: "38;5"},
+		},
+		{
+			name: "default colors",
+			sgr:  "\x1b[1;31;44m\x1b[39;49m",
+			want: Style{Bold: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(2, 10)
+			vt.WriteString("%s", tt.sgr+"x")
+			if got := vt.Cell(0, 0).Style; got != tt.want {
+				t.Errorf("style = %+v, want %+v", got, tt.want)
+			}
+			if got := vt.Cell(0, 1).Style; got != (Style{}) {
+				t.Errorf("unwritten cell style = %+v", got)
+			}
+
+			// Line styles the row so that it writes the same cells.
+			replay := NewVirtualTerminal(2, 10)
+			replay.WriteString("%s", vt.Line(0))
+			if got := replay.Cell(0, 0).Style; got != tt.want {
+				t.Errorf("replayed style = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVirtualTerminalWide(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+		col    int
+		// cells are the runes of the first row.
+		cells []rune
+	}{
+		{
+			name:   "wide characters",
+			writes: []string{"あい"},
+			want:   screenLines("あい", ""),
+			col:    4,
+			cells:  []rune{'あ', 0, 'い', 0, ' '},
+		},
+		{
+			name:   "wrap before the edge",
+			writes: []string{"abcdあ"},
+			want:   screenLines("abcd", "あ"),
+			col:    2,
+		},
+		{
+			name:   "overwrite the left half",
+			writes: []string{"あい\x1b[1;1Hx"},
+			want:   screenLines("x い", ""),
+			col:    1,
+			cells:  []rune{'x', ' ', 'い', 0, ' '},
+		},
+		{
+			name:   "overwrite the right half",
+			writes: []string{"あい\x1b[1;2Hx"},
+			want:   screenLines(" xい", ""),
+			col:    2,
+			cells:  []rune{' ', 'x', 'い', 0, ' '},
+		},
+		{
+			name:   "wide over the right half",
+			writes: []string{"aあい\x1b[1;3Hう"},
+			want:   screenLines("a う", ""),
+			col:    4,
+			cells:  []rune{'a', ' ', 'う', 0, ' '},
+		},
+		{
+			name:   "emoji",
+			writes: []string{"👍x"},
+			want:   screenLines("👍x", ""),
+			col:    3,
+			cells:  []rune{'👍', 0, 'x', ' ', ' '},
+		},
+		{
+			name:   "combining mark in the cluster",
+			writes: []string{"e\u0301x"},
+			want:   screenLines("e\u0301x", ""),
+			col:    2,
+			cells:  []rune{'e', 'x', ' ', ' ', ' '},
+		},
+		{
+			name:   "combining mark written alone",
+			writes: []string{"e", "\u0301", "x"},
+			want:   screenLines("e\u0301x", ""),
+			col:    2,
+			cells:  []rune{'e', 'x', ' ', ' ', ' '},
+		},
+		{
+			name:   "combining mark after a wide character",
+			writes: []string{"か", "\u3099"},
+			want:   screenLines("か\u3099", ""),
+			col:    2,
+			cells:  []rune{'か', 0, ' ', ' ', ' '},
+		},
+		{
+			name:   "combining mark at the start of the line",
+			writes: []string{"\u0301x"},
+			want:   screenLines("x", ""),
+			col:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(2, 5)
+			for _, s := range tt.writes {
+				vt.WriteString("%s", s)
+			}
+			if got := vt.Snapshot(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			if _, col := vt.Cursor(); col != tt.col {
+				t.Errorf("cursor column = %d, want %d", col, tt.col)
+			}
+			for i, r := range tt.cells {
+				if got := vt.Cell(0, i).Rune; got != r {
+					t.Errorf("cell %d = %q, want %q", i, got, r)
+				}
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps func(r *Recorder)
+		want  string
+		mouse bool
+		alt   bool
+	}{
+		{
+			name:  "open",
+			steps: func(r *Recorder) { r.Open() },
+			want:  ScreenEnableAlt + CursorHide + MouseEnable + PasteEnable,
+			mouse: true,
+			alt:   true,
+		},
+		{
+			name: "open and close",
+			steps: func(r *Recorder) {
+				r.Open()
+				r.Close()
+			},
+			want: ScreenEnableAlt + CursorHide + MouseEnable + PasteEnable +
+				ScreenDisableAlt + CursorShow + MouseDisable + PasteDisable,
+		},
+		{
+			name: "mouse toggled",
+			steps: func(r *Recorder) {
+				r.Open()
+				r.DisableMouse()
+			},
+			want: ScreenEnableAlt + CursorHide + MouseEnable + PasteEnable + MouseDisable,
+			alt:  true,
+		},
+		{
+			name: "mouse enabled again",
+			steps: func(r *Recorder) {
+				r.Open()
+				r.DisableMouse()
+				r.EnableMouse()
+			},
+			want:  ScreenEnableAlt + CursorHide + MouseEnable + PasteEnable + MouseDisable + MouseEnable,
+			mouse: true,
+			alt:   true,
+		},
+		{
+			name: "writes",
+			steps: func(r *Recorder) {
+				r.Write([]byte("a"))
+				r.WriteString("%d", 1)
+				r.Clear()
+				r.NextLine(2)
+			},
+			want: "a1" + ScreenClearAll + CursorHome + CursorNextLine + CursorNextLine,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(4, 10)
+			b := bytes.Buffer{}
+			r := NewRecorder(vt, &b)
+			tt.steps(r)
+			if got := b.String(); got != tt.want {
+				t.Errorf("recorded %q, want %q", got, tt.want)
+			}
+			if vt.MouseEnabled() != tt.mouse || vt.AltScreen() != tt.alt {
+				t.Errorf("terminal mouse, alt = %v, %v, want %v, %v", vt.MouseEnabled(), vt.AltScreen(), tt.mouse, tt.alt)
+			}
+
+			// The recording replays to the same modes.
+			replay := NewVirtualTerminal(4, 10)
+			replay.Write(b.Bytes())
+			if replay.MouseEnabled() != tt.mouse || replay.AltScreen() != tt.alt {
+				t.Errorf("replayed mouse, alt = %v, %v, want %v, %v", replay.MouseEnabled(), replay.AltScreen(), tt.mouse, tt.alt)
+			}
+		})
+	}
+}
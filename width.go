@@ -0,0 +1,147 @@
+package cwl
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+)
+
+// escapeLen returns the length of the escape sequence at the start of s, or
+// 0 if s does not start with one.
+func escapeLen(s string) int {
+	if len(s) == 0 || s[0] != 0x1b {
+		return 0
+	}
+	if len(s) == 1 {
+		return 1
+	}
+	if s[1] != '[' {
+		return 2
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// segments calls fn with each escape sequence and grapheme cluster of s, and
+// the number of columns it takes. Escape sequences take none. It stops when
+// fn returns false.
+func segments(s string, fn func(segment string, width int) bool) {
+	state := -1
+	for len(s) > 0 {
+		if n := escapeLen(s); n > 0 {
+			if !fn(s[:n], 0) {
+				return
+			}
+			s = s[n:]
+			state = -1
+			continue
+		}
+		end := strings.IndexByte(s, 0x1b)
+		if end < 0 {
+			end = len(s)
+		}
+		text := s[:end]
+		for len(text) > 0 {
+			var cluster string
+			var width int
+			cluster, text, width, state = uniseg.FirstGraphemeClusterInString(text, state)
+			if !fn(cluster, width) {
+				return
+			}
+		}
+		s = s[end:]
+	}
+}
+
+// StringWidth returns the number of terminal columns s takes, counting wide
+// characters as two and ignoring escape sequences.
+func StringWidth(s string) int {
+	width := 0
+	segments(s, func(_ string, w int) bool {
+		width += w
+		return true
+	})
+	return width
+}
+
+// Truncate shortens s to at most width columns, ending it with tail when it
+// is cut. Escape sequences are kept, and neither grapheme clusters nor wide
+// characters are split.
+func Truncate(s string, width int, tail string) string {
+	if StringWidth(s) <= width {
+		return s
+	}
+	width -= StringWidth(tail)
+	b := strings.Builder{}
+	used := 0
+	segments(s, func(segment string, w int) bool {
+		if used+w > width {
+			return false
+		}
+		b.WriteString(segment)
+		used += w
+		return true
+	})
+	if width < 0 {
+		return b.String()
+	}
+	return b.String() + tail
+}
+
+// Wrap splits s into lines of at most width columns, breaking at newlines
+// and wherever a line is full. Escape sequences are kept on the line they
+// appear in.
+func Wrap(s string, width int) []string {
+	lines := []string{}
+	for _, text := range strings.Split(s, "\n") {
+		b := strings.Builder{}
+		used := 0
+		segments(text, func(segment string, w int) bool {
+			if used+w > width && used > 0 {
+				lines = append(lines, b.String())
+				b.Reset()
+				used = 0
+			}
+			b.WriteString(segment)
+			used += w
+			return true
+		})
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+// printable removes control characters from s, keeping newlines and the SGR
+// escape sequences that color text. Other escape sequences, such as cursor
+// movements, are dropped so that log messages cannot garble the screen.
+func printable(s string) string {
+	b := strings.Builder{}
+	segments(s, func(segment string, w int) bool {
+		switch {
+		case segment[0] == 0x1b:
+			if strings.HasPrefix(segment, "\x1b[") && strings.HasSuffix(segment, "m") {
+				b.WriteString(segment)
+			}
+		case segment == "\n" || segment == "\r\n":
+			b.WriteString("\n")
+		case w > 0 || !isControl(segment):
+			b.WriteString(segment)
+		}
+		return true
+	})
+	return b.String()
+}
+
+func isControl(segment string) bool {
+	for _, r := range segment {
+		if unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}